@@ -23,14 +23,20 @@ import (
 	"compress/gzip"
 	"context"
 	"crypto/sha256"
+	"debug/elf"
 	"encoding/hex"
 	"errors"
+	"fmt"
 	"io"
+	"io/fs"
 	"log/slog"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
 	"runtime"
+	"slices"
+	"strings"
 	"syscall"
 	"text/template"
 	"time"
@@ -44,6 +50,32 @@ import (
 const (
 	checksumType   = "sha256"
 	checksumHexLen = sha256.Size * 2 // bytes to hex
+
+	// manifestType is the name of the per-file checksum manifest written after
+	// extraction, used to verify the integrity of installed files.
+	manifestType = "sha256sums"
+
+	// flagsType is the name of the marker file recording the install flags of
+	// an installed version, so that flag changes at the same version (e.g., a
+	// FIPS toggle) trigger a reinstall.
+	flagsType = "flags"
+
+	// tmpDirName is the default name of the scratch directory for downloads
+	// inside InstallDir.
+	tmpDirName = ".tmp"
+
+	// stagePrefix is the directory name prefix of staged replacement
+	// installations during a flags-only reinstall of the same version.
+	// The prefix keeps staged directories hidden from List.
+	stagePrefix = ".stage-"
+
+	// partialPrefix is the file name prefix of in-progress downloads in the
+	// scratch directory.
+	partialPrefix = "teleport-update-"
+
+	// cleanupAge is the minimum age of stale download artifacts removed by Cleanup.
+	// Artifacts younger than this may belong to an in-progress download.
+	cleanupAge = time.Hour
 )
 
 var (
@@ -69,18 +101,60 @@ var (
 type LocalInstaller struct {
 	// InstallDir contains each installation, named by version.
 	InstallDir string
+	// TempDir is the scratch directory for downloads before extraction.
+	// Defaults to a hidden subdirectory of InstallDir, so that scratch space
+	// resides on the same filesystem as the installation.
+	TempDir string
 	// LinkBinDir contains symlinks to the linked installation's binaries.
 	LinkBinDir string
 	// LinkServiceDir contains a symlink to the linked installation's systemd service.
 	LinkServiceDir string
 	// HTTP is an HTTP client for downloading Teleport.
 	HTTP *http.Client
+	// DownloadHeaders are included on every download request (e.g., auth
+	// tokens for private mirrors). Header values are never logged.
+	DownloadHeaders http.Header
+	// AllowedHosts restricts downloads to URLs with the listed hostnames
+	// when non-empty, guarding against URL template tampering.
+	AllowedHosts []string
+	// MaxDownloadSize is the maximum size of the Teleport download, in bytes.
+	// Oversized downloads are rejected before streaming begins when the size
+	// is declared, and aborted mid-stream otherwise.
+	// Defaults to no limit.
+	MaxDownloadSize int64
+	// ExtractTimeout bounds the tar extraction step.
+	// Defaults to no timeout.
+	ExtractTimeout time.Duration
 	// Log contains a logger.
 	Log *slog.Logger
+	// Progress is invoked periodically with download progress, if set.
+	// Total is -1 when the total download size is unknown.
+	Progress func(downloaded, total int64)
+	// VerifyStaticLinking refuses installs whose binaries depend on dynamic
+	// libraries not listed in AllowedLibraries.
+	VerifyStaticLinking bool
+	// AllowedLibraries lists dynamic libraries (sonames) that installed
+	// binaries may depend on when VerifyStaticLinking is set.
+	AllowedLibraries []string
 	// ReservedFreeTmpDisk is the amount of disk that must remain free in /tmp
 	ReservedFreeTmpDisk uint64
 	// ReservedFreeInstallDisk is the amount of disk that must remain free in the install directory.
 	ReservedFreeInstallDisk uint64
+	// PeerCache optionally serves archives already downloaded by other agents
+	// on the local network. Peers are tried before the download URL, and the
+	// checksum is always retrieved from the download URL, so a misbehaving
+	// peer can only cause a fallback to the download URL, not a bad install.
+	PeerCache PeerSource
+}
+
+// PeerSource fetches Teleport archives already downloaded and verified by
+// other agents on the local network, avoiding redundant CDN downloads across
+// large fleets. Archives served by peers are always verified against the
+// checksum published at the canonical download URL before use.
+type PeerSource interface {
+	// Fetch writes the archive published at the given download URL to w.
+	// Fetch must return an error wrapping os.ErrNotExist if no peer has the archive.
+	Fetch(ctx context.Context, w io.Writer, url string) error
 }
 
 // Remove a Teleport version directory from InstallDir.
@@ -125,43 +199,69 @@ func (li *LocalInstaller) Install(ctx context.Context, version, template string,
 		return trace.Wrap(err)
 	}
 	sumPath := filepath.Join(versionDir, checksumType)
+	flagsPath := filepath.Join(versionDir, flagsType)
 
-	// generate download URI from template
-	uri, err := makeURL(template, version, flags)
-	if err != nil {
-		return trace.Wrap(err)
-	}
-
-	// Get new and old checksums. If they match, skip download.
+	// Check for an existing, checksum-verified installation of the version.
+	// The checksum marker is only written after a successful extraction, so a
+	// readable marker indicates an intact installation and no network I/O is needed.
 	// Otherwise, clear the old version directory and re-download.
-	checksumURI := uri + "." + checksumType
-	newSum, err := li.getChecksum(ctx, checksumURI)
-	if err != nil {
-		return trace.Errorf("failed to download checksum from %s: %w", checksumURI, err)
-	}
-	oldSum, err := readChecksum(sumPath)
-	if err == nil {
-		if bytes.Equal(oldSum, newSum) {
+	destDir := versionDir
+	var replacing bool
+	switch _, err := readChecksum(sumPath); {
+	case err == nil:
+		// The tarball contents depend on the install flags, so a version
+		// installed with different flags must be reinstalled in place.
+		if storedFlags, err := readFlags(flagsPath); err == nil && storedFlags == flags {
 			li.Log.InfoContext(ctx, "Version already present.", "version", version)
 			return nil
 		}
-		li.Log.WarnContext(ctx, "Removing version that does not match checksum.", "version", version)
-		if err := li.Remove(ctx, version); err != nil {
+		li.Log.InfoContext(ctx, "Version present with different install flags. Reinstalling.", "version", version, "flags", flags.Strings())
+		// A flags-only reinstall may replace the active version, which must
+		// not be removed until a verified replacement is on disk. The
+		// replacement is staged in a hidden sibling directory and swapped in
+		// after extraction, so that a failed download or extraction leaves
+		// the old installation intact. The caller re-links and reloads
+		// Teleport after the install completes.
+		destDir = filepath.Join(li.InstallDir, stagePrefix+version)
+		if err := os.RemoveAll(destDir); err != nil {
 			return trace.Wrap(err)
 		}
-	} else if !errors.Is(err, os.ErrNotExist) {
+		replacing = true
+	case !errors.Is(err, os.ErrNotExist):
 		li.Log.WarnContext(ctx, "Removing version with unreadable checksum.", "version", version, "error", err)
 		if err := li.Remove(ctx, version); err != nil {
 			return trace.Wrap(err)
 		}
 	}
+	sumPath = filepath.Join(destDir, checksumType)
+	flagsPath = filepath.Join(destDir, flagsType)
+
+	// generate download URI from template
+	uri, err := makeURL(template, version, flags)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	if err := checkURLHost(uri, li.AllowedHosts); err != nil {
+		return trace.Wrap(err)
+	}
+
+	// Get the new checksum before downloading the tarball.
+	checksumURI := uri + "." + checksumType
+	newSum, err := li.getChecksum(ctx, checksumURI)
+	if err != nil {
+		return trace.Errorf("failed to download checksum from %s: %w", checksumURI, err)
+	}
 
 	// Verify that we have enough free temp space, then download tgz
-	freeTmp, err := utils.FreeDiskWithReserve(os.TempDir(), li.ReservedFreeTmpDisk)
+	tmpDir, err := li.tempDir()
+	if err != nil {
+		return trace.Errorf("failed to create temporary directory: %w", err)
+	}
+	freeTmp, err := utils.FreeDiskWithReserve(tmpDir, li.ReservedFreeTmpDisk)
 	if err != nil {
 		return trace.Errorf("failed to calculate free disk: %w", err)
 	}
-	f, err := os.CreateTemp("", "teleport-update-")
+	f, err := os.CreateTemp(tmpDir, partialPrefix)
 	if err != nil {
 		return trace.Errorf("failed to create temporary file: %w", err)
 	}
@@ -171,7 +271,7 @@ func (li *LocalInstaller) Install(ctx context.Context, version, template string,
 			li.Log.WarnContext(ctx, "Failed to cleanup temporary download.", "error", err)
 		}
 	}()
-	pathSum, err := li.download(ctx, f, int64(freeTmp), uri)
+	pathSum, err := li.fetch(ctx, f, int64(freeTmp), uri, newSum)
 	if err != nil {
 		return trace.Errorf("failed to download teleport: %w", err)
 	}
@@ -200,27 +300,150 @@ func (li *LocalInstaller) Install(ctx context.Context, version, template string,
 		return trace.Errorf("failed seek to start: %w", err)
 	}
 
-	// If there's an error after we start extracting, delete the version dir.
+	// If there's an error after we start extracting, delete the destination dir.
 	defer func() {
 		if err != nil {
-			if err := os.RemoveAll(versionDir); err != nil {
-				li.Log.WarnContext(ctx, "Failed to cleanup broken version extraction.", "error", err, "dir", versionDir)
+			if err := os.RemoveAll(destDir); err != nil {
+				li.Log.WarnContext(ctx, "Failed to cleanup broken version extraction.", "error", err, "dir", destDir)
 			}
 		}
 	}()
 
 	// Extract tgz into version directory.
-	if err := li.extract(ctx, versionDir, f, n); err != nil {
+	// A hung extraction (e.g., slow disk) is bounded by ExtractTimeout, if set;
+	// closing the tgz file aborts the extraction.
+	extractCtx := ctx
+	if li.ExtractTimeout > 0 {
+		var cancel context.CancelFunc
+		extractCtx, cancel = context.WithTimeout(ctx, li.ExtractTimeout)
+		defer cancel()
+		stop := context.AfterFunc(extractCtx, func() {
+			_ = f.Close() // safe to close file multiple times
+		})
+		defer stop()
+	}
+	if err := li.extract(extractCtx, destDir, f, n); err != nil {
+		if errors.Is(extractCtx.Err(), context.DeadlineExceeded) {
+			return trace.Errorf("extraction timed out after %s", li.ExtractTimeout)
+		}
 		return trace.Errorf("failed to extract teleport: %w", err)
 	}
+	// Refuse binaries that would fail at runtime due to missing dynamic libraries.
+	if li.VerifyStaticLinking {
+		if err := li.verifyStaticBinaries(ctx, destDir); err != nil {
+			return trace.Wrap(err)
+		}
+	}
+	// Write the per-file checksum manifest so the installation can be verified later.
+	if err := writeManifest(destDir); err != nil {
+		return trace.Errorf("failed to write file manifest: %w", err)
+	}
+	// Record the install flags, so that flag changes at the same version
+	// trigger a reinstall.
+	if err := renameio.WriteFile(flagsPath, []byte(strings.Join(flags.Strings(), "\n")), 0755); err != nil {
+		return trace.Errorf("failed to write install flags: %w", err)
+	}
 	// Write the checksum last. This marks the version directory as valid.
 	err = renameio.WriteFile(sumPath, []byte(hex.EncodeToString(newSum)), 0755)
 	if err != nil {
 		return trace.Errorf("failed to write checksum: %w", err)
 	}
+	if replacing {
+		// Swap the verified replacement in for the old installation.
+		// The old checksum is removed first, so that an interruption between
+		// the two steps reads as a broken installation rather than a valid
+		// one with the old flags.
+		if err := os.Remove(filepath.Join(versionDir, checksumType)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return trace.Wrap(err)
+		}
+		if err := os.RemoveAll(versionDir); err != nil {
+			return trace.Wrap(err)
+		}
+		if err := os.Rename(destDir, versionDir); err != nil {
+			return trace.Wrap(err)
+		}
+	}
 	return nil
 }
 
+// fetch retrieves the archive at url into f, preferring the local peer cache
+// when one is configured. Archives that no peer has, or that fail verification
+// against the expected checksum, fall back to the canonical download URL.
+func (li *LocalInstaller) fetch(ctx context.Context, f *os.File, max int64, uri string, want []byte) ([]byte, error) {
+	if li.PeerCache != nil {
+		switch sum, err := li.fetchPeer(ctx, f, max, uri); {
+		case errors.Is(err, os.ErrNotExist):
+			li.Log.DebugContext(ctx, "Download not available from peer cache.", "url", uri)
+		case err != nil:
+			li.Log.WarnContext(ctx, "Failed to fetch download from peer cache.", "error", err)
+		case !bytes.Equal(sum, want):
+			li.Log.WarnContext(ctx, "Peer cache served a download with a mismatched checksum. Falling back to canonical source.", "url", uri)
+		default:
+			li.Log.InfoContext(ctx, "Download fetched from peer cache.", "url", uri)
+			return sum, nil
+		}
+		// Discard any partial or corrupt peer data before downloading.
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return nil, trace.Wrap(err)
+		}
+		if err := f.Truncate(0); err != nil {
+			return nil, trace.Wrap(err)
+		}
+	}
+	return li.download(ctx, f, max, uri)
+}
+
+// fetchPeer retrieves the archive at url from the peer cache, returning the
+// sha256 of the bytes written to w.
+func (li *LocalInstaller) fetchPeer(ctx context.Context, w io.Writer, max int64, uri string) ([]byte, error) {
+	shaReader := sha256.New()
+	err := li.PeerCache.Fetch(ctx, &limitWriter{
+		w: io.MultiWriter(w, shaReader),
+		n: max,
+	}, uri)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return shaReader.Sum(nil), nil
+}
+
+// limitWriter errors if more than n bytes are written to it.
+type limitWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (lw *limitWriter) Write(p []byte) (int, error) {
+	lw.n -= int64(len(p))
+	if lw.n < 0 {
+		return 0, trace.Errorf("download exceeds allowed size")
+	}
+	return lw.w.Write(p)
+}
+
+// readFlags returns the install flags recorded for an installed version.
+// A missing marker is treated as an install without flags.
+func readFlags(path string) (InstallFlags, error) {
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, trace.Wrap(err)
+	}
+	var names []string
+	for _, line := range strings.Split(string(b), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			names = append(names, line)
+		}
+	}
+	flags, err := parseInstallFlags(names)
+	if err != nil {
+		return 0, trace.Wrap(err)
+	}
+	return flags, nil
+}
+
 // makeURL to download the Teleport tgz.
 func makeURL(uriTmpl, version string, flags InstallFlags) (string, error) {
 	tmpl, err := template.New("uri").Parse(uriTmpl)
@@ -245,6 +468,22 @@ func makeURL(uriTmpl, version string, flags InstallFlags) (string, error) {
 	return uriBuf.String(), nil
 }
 
+// checkURLHost ensures that the host of the expanded download URL is on the
+// allowlist, if one is configured.
+func checkURLHost(uri string, allowed []string) error {
+	if len(allowed) == 0 {
+		return nil
+	}
+	u, err := url.Parse(uri)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	if !slices.Contains(allowed, u.Hostname()) {
+		return trace.Errorf("download host %q is not in the allowed hosts list", u.Hostname())
+	}
+	return nil
+}
+
 // readChecksum from the version directory.
 func readChecksum(path string) ([]byte, error) {
 	f, err := os.Open(path)
@@ -265,6 +504,202 @@ func readChecksum(path string) ([]byte, error) {
 	return sum, nil
 }
 
+// VerifyError reports a file within an installed version that failed
+// integrity verification.
+type VerifyError struct {
+	// Version of Teleport that failed verification.
+	Version string
+	// Path of the file that failed verification, relative to the version directory.
+	Path string
+	// Err describes why verification failed.
+	Err error
+}
+
+func (e *VerifyError) Error() string {
+	return fmt.Sprintf("file %q in version %q failed verification: %v", e.Path, e.Version, e.Err)
+}
+
+func (e *VerifyError) Unwrap() error {
+	return e.Err
+}
+
+// Verify the integrity of a Teleport version directory in InstallDir.
+// Extracted files are re-hashed against the manifest written at install time.
+// See Installer interface for additional specs.
+func (li *LocalInstaller) Verify(ctx context.Context, version string) error {
+	versionDir, err := li.versionDir(version)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	if _, err := readChecksum(filepath.Join(versionDir, checksumType)); err != nil {
+		return trace.Wrap(&VerifyError{Version: version, Path: checksumType, Err: err})
+	}
+	entries, err := readManifest(filepath.Join(versionDir, manifestType))
+	if err != nil {
+		return trace.Wrap(&VerifyError{Version: version, Path: manifestType, Err: err})
+	}
+	for _, e := range entries {
+		if err := ctx.Err(); err != nil {
+			return trace.Wrap(err)
+		}
+		sum, err := hashFile(filepath.Join(versionDir, filepath.FromSlash(e.path)))
+		if err != nil {
+			return trace.Wrap(&VerifyError{Version: version, Path: e.path, Err: err})
+		}
+		if !bytes.Equal(sum, e.sum) {
+			return trace.Wrap(&VerifyError{Version: version, Path: e.path, Err: errors.New("checksum mismatch")})
+		}
+	}
+	return nil
+}
+
+// verifyStaticBinaries ensures that extracted binaries do not depend on
+// dynamic libraries outside of AllowedLibraries.
+// Binaries that would fail to start due to missing libraries are refused at
+// install time, rather than failing at first start.
+func (li *LocalInstaller) verifyStaticBinaries(ctx context.Context, versionDir string) error {
+	binDir := filepath.Join(versionDir, "bin")
+	entries, err := os.ReadDir(binDir)
+	if err != nil {
+		return trace.Errorf("failed to find Teleport binary directory: %w", err)
+	}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		libs, err := dynamicLibraries(filepath.Join(binDir, entry.Name()))
+		if errors.Is(err, ErrNotSupported) {
+			li.Log.DebugContext(ctx, "Skipping dynamic dependency check for non-ELF binary.", "binary", entry.Name())
+			continue
+		}
+		if err != nil {
+			return trace.Errorf("failed to read dynamic dependencies of %s: %w", entry.Name(), err)
+		}
+		for _, lib := range libs {
+			if !slices.Contains(li.AllowedLibraries, lib) {
+				return trace.Errorf("binary %s requires dynamic library %q, which is not allowed", entry.Name(), lib)
+			}
+		}
+	}
+	return nil
+}
+
+// dynamicLibraries returns the sonames of shared libraries required by the ELF
+// binary at path, read from the DT_NEEDED entries of the dynamic section.
+// Statically linked binaries return no libraries.
+// Returns ErrNotSupported if the binary is not in ELF format.
+func dynamicLibraries(path string) ([]string, error) {
+	f, err := elf.Open(path)
+	var fmtErr *elf.FormatError
+	if errors.As(err, &fmtErr) {
+		return nil, trace.Wrap(ErrNotSupported)
+	}
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	defer f.Close()
+	libs, err := f.DynString(elf.DT_NEEDED)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return libs, nil
+}
+
+// manifestEntry pairs a file path (relative to the version directory,
+// with /-separators) with its expected SHA256 checksum.
+type manifestEntry struct {
+	path string
+	sum  []byte
+}
+
+// writeManifest hashes all extracted files in versionDir and atomically writes
+// the manifest in sha256sum format (hex checksum, two spaces, path).
+func writeManifest(versionDir string) error {
+	var buf bytes.Buffer
+	err := filepath.WalkDir(versionDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return trace.Wrap(err)
+		}
+		if d.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(versionDir, path)
+		if err != nil {
+			return trace.Wrap(err)
+		}
+		rel = filepath.ToSlash(rel)
+		// The checksum marker and the manifest itself are not part of the payload.
+		if rel == checksumType || rel == manifestType {
+			return nil
+		}
+		sum, err := hashFile(path)
+		if err != nil {
+			return trace.Wrap(err)
+		}
+		fmt.Fprintf(&buf, "%s  %s\n", hex.EncodeToString(sum), rel)
+		return nil
+	})
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	return trace.Wrap(renameio.WriteFile(filepath.Join(versionDir, manifestType), buf.Bytes(), 0755))
+}
+
+// readManifest parses a manifest written by writeManifest.
+func readManifest(path string) ([]manifestEntry, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	var entries []manifestEntry
+	for _, line := range strings.Split(string(b), "\n") {
+		if line == "" {
+			continue
+		}
+		sumHex, name, ok := strings.Cut(line, "  ")
+		if !ok {
+			return nil, trace.Errorf("invalid manifest line: %q", line)
+		}
+		sum, err := hex.DecodeString(sumHex)
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+		entries = append(entries, manifestEntry{path: name, sum: sum})
+	}
+	return entries, nil
+}
+
+// hashFile returns the SHA256 checksum of the file at path.
+func hashFile(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	defer f.Close()
+	sha := sha256.New()
+	if _, err := io.Copy(sha, f); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return sha.Sum(nil), nil
+}
+
+// applyDownloadHeaders adds the configured download headers to the request.
+// Only header names are logged; values may contain credentials.
+func (li *LocalInstaller) applyDownloadHeaders(ctx context.Context, req *http.Request) {
+	if len(li.DownloadHeaders) == 0 {
+		return
+	}
+	names := make([]string, 0, len(li.DownloadHeaders))
+	for name, values := range li.DownloadHeaders {
+		for _, v := range values {
+			req.Header.Add(name, v)
+		}
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	li.Log.DebugContext(ctx, "Including custom download headers.", "headers", names)
+}
+
 func (li *LocalInstaller) getChecksum(ctx context.Context, url string) ([]byte, error) {
 	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
 	defer cancel()
@@ -273,6 +708,7 @@ func (li *LocalInstaller) getChecksum(ctx context.Context, url string) ([]byte,
 	if err != nil {
 		return nil, trace.Wrap(err)
 	}
+	li.applyDownloadHeaders(ctx, req)
 	resp, err := li.HTTP.Do(req)
 	if err != nil {
 		return nil, trace.Wrap(err)
@@ -302,6 +738,7 @@ func (li *LocalInstaller) download(ctx context.Context, w io.Writer, max int64,
 	if err != nil {
 		return nil, trace.Wrap(err)
 	}
+	li.applyDownloadHeaders(ctx, req)
 	resp, err := li.HTTP.Do(req)
 	if err != nil {
 		return nil, trace.Wrap(err)
@@ -315,27 +752,45 @@ func (li *LocalInstaller) download(ctx context.Context, w io.Writer, max int64,
 	}
 	li.Log.InfoContext(ctx, "Downloading Teleport tarball.", "url", url, "size", resp.ContentLength)
 
-	// Ensure there's enough space in /tmp for the download.
+	// Ensure the download fits within the configured size limit and /tmp.
 	size := resp.ContentLength
+	if li.MaxDownloadSize > 0 && size > li.MaxDownloadSize {
+		return nil, trace.Errorf("size of download (%d bytes) exceeds maximum allowed size (%d bytes)", resp.ContentLength, li.MaxDownloadSize)
+	}
 	if size < 0 {
 		li.Log.WarnContext(ctx, "Content length missing from response, unable to verify Teleport download size.")
 		size = max
+		if li.MaxDownloadSize > 0 && li.MaxDownloadSize < size {
+			size = li.MaxDownloadSize
+		}
 	} else if size > max {
 		return nil, trace.Errorf("size of download (%d bytes) exceeds available disk space (%d bytes)", resp.ContentLength, max)
 	}
 	// Calculate checksum concurrently with download.
+	// Track progress and ETA as bytes are copied.
 	shaReader := sha256.New()
-	n, err := io.CopyN(w, io.TeeReader(resp.Body, shaReader), size)
+	progress := newProgressWriter(ctx, li.Log, resp.ContentLength, li.Progress)
+	n, err := io.Copy(io.MultiWriter(w, progress), io.TeeReader(io.LimitReader(resp.Body, size), shaReader))
 	if err != nil {
 		return nil, trace.Wrap(err)
 	}
 	if resp.ContentLength >= 0 && n != resp.ContentLength {
 		return nil, trace.Errorf("mismatch in Teleport download size")
 	}
+	// Chunked responses have no declared size, so abort mid-stream if the
+	// response continues past the allowed size.
+	if n == size {
+		if _, err := resp.Body.Read(make([]byte, 1)); err == nil {
+			return nil, trace.Errorf("download exceeds allowed size (%d bytes)", size)
+		}
+	}
 	return shaReader.Sum(nil), nil
 }
 
 func (li *LocalInstaller) extract(ctx context.Context, dstDir string, src io.Reader, max int64) error {
+	if err := ctx.Err(); err != nil {
+		return trace.Wrap(err)
+	}
 	if err := os.MkdirAll(dstDir, 0755); err != nil {
 		return trace.Wrap(err)
 	}
@@ -376,6 +831,172 @@ func uncompressedSize(f io.Reader) (int64, error) {
 	return n, nil
 }
 
+// tempDir returns the scratch directory for downloads, creating it if missing.
+func (li *LocalInstaller) tempDir() (string, error) {
+	dir := li.TempDir
+	if dir == "" {
+		dir = filepath.Join(li.InstallDir, tmpDirName)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", trace.Wrap(err)
+	}
+	return dir, nil
+}
+
+// CheckDisk verifies that the installation directories are writable and that
+// enough free disk space remains above the configured reserves for an install.
+// See Installer interface for additional specs.
+func (li *LocalInstaller) CheckDisk(ctx context.Context) error {
+	tmpDir, err := li.tempDir()
+	if err != nil {
+		return trace.Errorf("failed to create temporary directory: %w", err)
+	}
+	for _, dir := range []string{li.InstallDir, li.LinkBinDir} {
+		if err := checkWritableDir(dir); err != nil {
+			return trace.Wrap(err)
+		}
+	}
+	if _, err := utils.FreeDiskWithReserve(tmpDir, li.ReservedFreeTmpDisk); err != nil {
+		return trace.Errorf("insufficient free disk in %s: %w", tmpDir, err)
+	}
+	if _, err := utils.FreeDiskWithReserve(li.InstallDir, li.ReservedFreeInstallDisk); err != nil {
+		return trace.Errorf("insufficient free disk in %s: %w", li.InstallDir, err)
+	}
+	return nil
+}
+
+// checkWritableDir verifies that dir is writable by creating and removing a
+// temporary file, creating dir first if it is missing.
+func checkWritableDir(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return trace.Errorf("failed to create %s: %w", dir, err)
+	}
+	f, err := os.CreateTemp(dir, ".teleport-update-check-")
+	if err != nil {
+		return trace.Errorf("%s is not writable: %w", dir, err)
+	}
+	name := f.Name()
+	if err := f.Close(); err != nil {
+		return trace.Wrap(err)
+	}
+	if err := os.Remove(name); err != nil {
+		return trace.Wrap(err)
+	}
+	return nil
+}
+
+// Cleanup removes orphaned partial downloads left behind by interrupted installs.
+// Only artifacts older than cleanupAge are removed, so that partial downloads
+// belonging to a concurrently running updater (which must hold the updater
+// file lock) are never deleted.
+// See Installer interface for additional specs.
+func (li *LocalInstaller) Cleanup(ctx context.Context) error {
+	dir := li.TempDir
+	if dir == "" {
+		dir = filepath.Join(li.InstallDir, tmpDirName)
+	}
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasPrefix(entry.Name(), partialPrefix) {
+			continue
+		}
+		fi, err := entry.Info()
+		if err != nil {
+			return trace.Wrap(err)
+		}
+		if time.Since(fi.ModTime()) < cleanupAge {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		if err := os.Remove(path); err != nil {
+			li.Log.WarnContext(ctx, "Failed to remove stale partial download.", "path", path, "error", err)
+			continue
+		}
+		li.Log.InfoContext(ctx, "Removed stale partial download.", "path", path)
+	}
+	return nil
+}
+
+// InstallInfo describes an installed version of Teleport.
+type InstallInfo struct {
+	// Version of Teleport.
+	Version string `json:"version"`
+	// Flags recorded at install time (e.g., Enterprise, FIPS).
+	Flags InstallFlags `json:"flags,omitempty"`
+	// Size of the version directory on disk, in bytes.
+	Size int64 `json:"size"`
+}
+
+// Stat returns details (install flags, size on disk) for an installed version.
+// See Installer interface for additional specs.
+func (li *LocalInstaller) Stat(ctx context.Context, version string) (InstallInfo, error) {
+	versionDir, err := li.versionDir(version)
+	if err != nil {
+		return InstallInfo{}, trace.Wrap(err)
+	}
+	flags, err := readFlags(filepath.Join(versionDir, flagsType))
+	if err != nil {
+		return InstallInfo{}, trace.Wrap(err)
+	}
+	var size int64
+	err = filepath.WalkDir(versionDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return trace.Wrap(err)
+		}
+		if d.IsDir() || !d.Type().IsRegular() {
+			return nil
+		}
+		fi, err := d.Info()
+		if err != nil {
+			return trace.Wrap(err)
+		}
+		size += fi.Size()
+		return nil
+	})
+	if err != nil {
+		return InstallInfo{}, trace.Wrap(err)
+	}
+	return InstallInfo{
+		Version: version,
+		Flags:   flags,
+		Size:    size,
+	}, nil
+}
+
+// LinkedVersion returns the installed version that the teleport binary link
+// currently points to.
+// Returns an empty string if the link is missing, is not a symlink, or points
+// outside of the managed installation directory.
+// See Installer interface for additional specs.
+func (li *LocalInstaller) LinkedVersion(ctx context.Context) (string, error) {
+	target, err := os.Readlink(filepath.Join(li.LinkBinDir, "teleport"))
+	if errors.Is(err, os.ErrNotExist) ||
+		errors.Is(err, os.ErrInvalid) ||
+		errors.Is(err, syscall.EINVAL) { // workaround missing ErrInvalid wrapper
+		// Missing links and non-linked installs of Teleport are not tracked.
+		return "", nil
+	}
+	if err != nil {
+		return "", trace.Wrap(err)
+	}
+	version := filepath.Base(filepath.Dir(filepath.Dir(target)))
+	versionDir, err := li.versionDir(version)
+	if err != nil {
+		return "", trace.Wrap(err)
+	}
+	if filepath.Dir(target) != filepath.Join(versionDir, "bin") {
+		// The link points outside of the managed installation directory.
+		return "", nil
+	}
+	return version, nil
+}
+
 // List installed versions of Teleport.
 func (li *LocalInstaller) List(ctx context.Context) (versions []string, err error) {
 	entries, err := os.ReadDir(li.InstallDir)
@@ -386,6 +1007,10 @@ func (li *LocalInstaller) List(ctx context.Context) (versions []string, err erro
 		if !entry.IsDir() {
 			continue
 		}
+		// Hidden directories (e.g., scratch space) are not versions.
+		if strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
 		versions = append(versions, entry.Name())
 	}
 	return versions, nil
@@ -480,9 +1105,113 @@ func (li *LocalInstaller) Link(ctx context.Context, version string) (revert func
 			new: newname,
 		})
 	}
+
+	// Verify that every binary referenced by the systemd unit resolves after
+	// linking, so that a reload will not fail due to a missing binary.
+	if err := checkServiceBinaries(oldname); err != nil {
+		return revert, trace.Wrap(err)
+	}
 	return revert, nil
 }
 
+// Unlink removes the system links for the specified version.
+// Links are only removed if they point to the specified version, so links to
+// other versions (or unmanaged files) are always left untouched.
+// Returns ErrNotNeeded if no links for the version are present.
+// See Installer interface for additional specs.
+func (li *LocalInstaller) Unlink(ctx context.Context, version string) error {
+	versionDir, err := li.versionDir(version)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+
+	// remove binary links that point into this version
+
+	var removed bool
+	binDir := filepath.Join(versionDir, "bin")
+	entries, err := os.ReadDir(binDir)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return trace.Wrap(err)
+	}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		newname := filepath.Join(li.LinkBinDir, entry.Name())
+		orig, err := os.Readlink(newname)
+		if err != nil || orig != filepath.Join(binDir, entry.Name()) {
+			continue
+		}
+		if err := os.Remove(newname); err != nil {
+			return trace.Wrap(err)
+		}
+		removed = true
+	}
+
+	// remove the systemd service link if it points into this version
+
+	oldname := filepath.Join(versionDir, servicePath)
+	newname := filepath.Join(li.LinkServiceDir, filepath.Base(servicePath))
+	if orig, err := os.Readlink(newname); err == nil && orig == oldname {
+		if err := os.Remove(newname); err != nil {
+			return trace.Wrap(err)
+		}
+		removed = true
+	}
+
+	if !removed {
+		return trace.Wrap(ErrNotNeeded)
+	}
+	return nil
+}
+
+// checkServiceBinaries ensures that every binary referenced by Exec*
+// directives in the systemd unit file at unitPath exists on disk.
+func checkServiceBinaries(unitPath string) error {
+	bins, err := serviceBinaries(unitPath)
+	if errors.Is(err, os.ErrNotExist) {
+		// Installations without a systemd unit have nothing to verify.
+		return nil
+	}
+	if err != nil {
+		return trace.Errorf("failed to read systemd unit: %w", err)
+	}
+	for _, bin := range bins {
+		if _, err := os.Stat(bin); err != nil {
+			return trace.Errorf("binary %q referenced by systemd unit is not installed: %w", bin, err)
+		}
+	}
+	return nil
+}
+
+// serviceBinaries returns the absolute paths of binaries referenced by Exec*
+// directives (ExecStart, ExecStartPre, etc.) in the systemd unit file at path.
+func serviceBinaries(path string) ([]string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	var bins []string
+	for _, line := range strings.Split(string(b), "\n") {
+		key, value, ok := strings.Cut(strings.TrimSpace(line), "=")
+		if !ok || !strings.HasPrefix(key, "Exec") {
+			continue
+		}
+		fields := strings.Fields(value)
+		if len(fields) == 0 {
+			continue
+		}
+		// Strip systemd executable path prefixes (e.g., "-/usr/bin/foo").
+		bin := strings.TrimLeft(fields[0], "@-:+!")
+		// Non-absolute paths (e.g., variable expansions) cannot be verified.
+		if !filepath.IsAbs(bin) {
+			continue
+		}
+		bins = append(bins, bin)
+	}
+	return bins, nil
+}
+
 // tryLink attempts to create a symlink, atomically replacing an existing link if already present.
 // If a non-symlink file or directory exists in newname already, tryLink errors.
 func tryLink(oldname, newname string) (orig string, err error) {
@@ -24,6 +24,8 @@ import (
 	"compress/gzip"
 	"context"
 	"crypto/sha256"
+	"debug/elf"
+	"encoding/binary"
 	"encoding/hex"
 	"io"
 	"log/slog"
@@ -35,6 +37,7 @@ import (
 	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -51,6 +54,7 @@ func TestLocalInstaller_Install(t *testing.T) {
 		reservedTmp     uint64
 		reservedInstall uint64
 		existingSum     string
+		allowedHosts    []string
 		flags           InstallFlags
 
 		errMatch string
@@ -70,6 +74,15 @@ func TestLocalInstaller_Install(t *testing.T) {
 			name:        "unreadable checksum",
 			existingSum: "bad",
 		},
+		{
+			name:         "download host on allowlist",
+			allowedHosts: []string{"127.0.0.1"},
+		},
+		{
+			name:         "download host not on allowlist",
+			allowedHosts: []string{"cdn.example.com"},
+			errMatch:     "not in the allowed hosts list",
+		},
 		{
 			name:        "out of space in /tmp",
 			reservedTmp: reservedFreeDisk * 1_000_000_000,
@@ -120,6 +133,7 @@ func TestLocalInstaller_Install(t *testing.T) {
 				InstallDir:              dir,
 				HTTP:                    http.DefaultClient,
 				Log:                     slog.Default(),
+				AllowedHosts:            tt.allowedHosts,
 				ReservedFreeTmpDisk:     tt.reservedTmp,
 				ReservedFreeInstallDisk: tt.reservedInstall,
 			}
@@ -153,6 +167,611 @@ func TestLocalInstaller_Install(t *testing.T) {
 	}
 }
 
+func TestLocalInstaller_PeerCache(t *testing.T) {
+	t.Parallel()
+	const version = "new-version"
+
+	tgz, _ := testTGZ(t, version)
+
+	tests := []struct {
+		name string
+		peer *testPeerSource
+
+		tgzRequests int
+	}{
+		{
+			name: "valid archive from peer",
+			peer: &testPeerSource{data: tgz.Bytes()},
+
+			// The archive is served by the peer, so only the checksum is
+			// downloaded from the canonical source.
+			tgzRequests: 0,
+		},
+		{
+			name: "invalid archive from peer",
+			peer: &testPeerSource{data: []byte("corrupt")},
+
+			tgzRequests: 1,
+		},
+		{
+			name: "peer miss",
+			peer: &testPeerSource{err: os.ErrNotExist},
+
+			tgzRequests: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+
+			var tgzRequests int
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				tgz, sum := testTGZ(t, version)
+				var out *bytes.Buffer
+				if strings.HasSuffix(r.URL.Path, "."+checksumType) {
+					out = bytes.NewBufferString(sum)
+				} else {
+					tgzRequests++
+					out = tgz
+				}
+				w.Header().Set("Content-Length", strconv.Itoa(out.Len()))
+				_, err := io.Copy(w, out)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}))
+			t.Cleanup(server.Close)
+
+			installer := &LocalInstaller{
+				InstallDir: dir,
+				HTTP:       http.DefaultClient,
+				Log:        slog.Default(),
+				PeerCache:  tt.peer,
+			}
+			ctx := context.Background()
+			err := installer.Install(ctx, version, server.URL+"/{{.Version}}", 0)
+			require.NoError(t, err)
+			require.Equal(t, 1, tt.peer.calls)
+			require.Equal(t, tt.tgzRequests, tgzRequests)
+
+			v, err := os.ReadFile(filepath.Join(dir, version, "bin", "teleport"))
+			require.NoError(t, err)
+			require.Equal(t, version, string(v))
+		})
+	}
+}
+
+// testPeerSource serves a fixed archive (or error) as a local peer cache.
+type testPeerSource struct {
+	data  []byte
+	err   error
+	calls int
+}
+
+func (ps *testPeerSource) Fetch(_ context.Context, w io.Writer, url string) error {
+	ps.calls++
+	if ps.err != nil {
+		return ps.err
+	}
+	_, err := w.Write(ps.data)
+	return err
+}
+
+func TestLocalInstaller_InstallCached(t *testing.T) {
+	t.Parallel()
+	const version = "new-version"
+
+	dir := t.TempDir()
+
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		tgz, sum := testTGZ(t, version)
+		var out *bytes.Buffer
+		if strings.HasSuffix(r.URL.Path, "."+checksumType) {
+			out = bytes.NewBufferString(sum)
+		} else {
+			out = tgz
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(out.Len()))
+		_, err := io.Copy(w, out)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	installer := &LocalInstaller{
+		InstallDir: dir,
+		HTTP:       http.DefaultClient,
+		Log:        slog.Default(),
+	}
+	ctx := context.Background()
+	err := installer.Install(ctx, version, server.URL+"/{{.Version}}", 0)
+	require.NoError(t, err)
+	require.Equal(t, 2, requests) // checksum + tarball
+
+	// A second install of an intact version must not touch the network.
+	err = installer.Install(ctx, version, server.URL+"/{{.Version}}", 0)
+	require.NoError(t, err)
+	require.Equal(t, 2, requests)
+
+	// A corrupted checksum marker forces a re-download.
+	err = os.WriteFile(filepath.Join(dir, version, checksumType), []byte("bad"), os.ModePerm)
+	require.NoError(t, err)
+	err = installer.Install(ctx, version, server.URL+"/{{.Version}}", 0)
+	require.NoError(t, err)
+	require.Equal(t, 4, requests)
+
+	// Changed install flags force a reinstall of the same version.
+	err = installer.Install(ctx, version, server.URL+"/{{.Version}}", FlagFIPS)
+	require.NoError(t, err)
+	require.Equal(t, 6, requests)
+	b, err := os.ReadFile(filepath.Join(dir, version, flagsType))
+	require.NoError(t, err)
+	require.Equal(t, "FIPS", string(b))
+
+	// Unchanged install flags do not.
+	err = installer.Install(ctx, version, server.URL+"/{{.Version}}", FlagFIPS)
+	require.NoError(t, err)
+	require.Equal(t, 6, requests)
+}
+
+func TestLocalInstaller_ReinstallPreservesOld(t *testing.T) {
+	t.Parallel()
+	const version = "new-version"
+
+	dir := t.TempDir()
+
+	var fail bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fail {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		tgz, sum := testTGZ(t, version)
+		var out *bytes.Buffer
+		if strings.HasSuffix(r.URL.Path, "."+checksumType) {
+			out = bytes.NewBufferString(sum)
+		} else {
+			out = tgz
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(out.Len()))
+		_, err := io.Copy(w, out)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	installer := &LocalInstaller{
+		InstallDir: dir,
+		HTTP:       http.DefaultClient,
+		Log:        slog.Default(),
+	}
+	ctx := context.Background()
+	err := installer.Install(ctx, version, server.URL+"/{{.Version}}", 0)
+	require.NoError(t, err)
+
+	// A failed reinstall with changed flags must leave the old installation intact.
+	fail = true
+	err = installer.Install(ctx, version, server.URL+"/{{.Version}}", FlagFIPS)
+	require.Error(t, err)
+	v, err := os.ReadFile(filepath.Join(dir, version, "bin", "teleport"))
+	require.NoError(t, err)
+	require.Equal(t, version, string(v))
+	b, err := os.ReadFile(filepath.Join(dir, version, flagsType))
+	require.NoError(t, err)
+	require.Empty(t, string(b))
+
+	// Once the download recovers, the reinstall replaces the old installation.
+	fail = false
+	err = installer.Install(ctx, version, server.URL+"/{{.Version}}", FlagFIPS)
+	require.NoError(t, err)
+	b, err = os.ReadFile(filepath.Join(dir, version, flagsType))
+	require.NoError(t, err)
+	require.Equal(t, "FIPS", string(b))
+
+	// Staged directories are never reported as installed versions.
+	versions, err := installer.List(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []string{version}, versions)
+}
+
+func TestLocalInstaller_MaxDownloadSize(t *testing.T) {
+	t.Parallel()
+	const version = "new-version"
+
+	t.Run("oversized content length", func(t *testing.T) {
+		t.Parallel()
+		dir := t.TempDir()
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			tgz, sum := testTGZ(t, version)
+			var out *bytes.Buffer
+			if strings.HasSuffix(r.URL.Path, "."+checksumType) {
+				out = bytes.NewBufferString(sum)
+			} else {
+				out = tgz
+			}
+			w.Header().Set("Content-Length", strconv.Itoa(out.Len()))
+			_, err := io.Copy(w, out)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}))
+		t.Cleanup(server.Close)
+
+		installer := &LocalInstaller{
+			InstallDir:      dir,
+			HTTP:            http.DefaultClient,
+			Log:             slog.Default(),
+			MaxDownloadSize: 10,
+		}
+		err := installer.Install(context.Background(), version, server.URL+"/{{.Version}}", 0)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "maximum allowed size")
+	})
+
+	t.Run("oversized chunked stream", func(t *testing.T) {
+		t.Parallel()
+		dir := t.TempDir()
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if strings.HasSuffix(r.URL.Path, "."+checksumType) {
+				_, sum := testTGZ(t, version)
+				out := bytes.NewBufferString(sum)
+				w.Header().Set("Content-Length", strconv.Itoa(out.Len()))
+				_, err := io.Copy(w, out)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			// Flushing between writes forces a chunked response without a
+			// Content-Length header.
+			for i := 0; i < 10; i++ {
+				_, err := w.Write(bytes.Repeat([]byte("a"), 10))
+				if err != nil {
+					t.Fatal(err)
+				}
+				w.(http.Flusher).Flush()
+			}
+		}))
+		t.Cleanup(server.Close)
+
+		installer := &LocalInstaller{
+			InstallDir:      dir,
+			HTTP:            http.DefaultClient,
+			Log:             slog.Default(),
+			MaxDownloadSize: 50,
+		}
+		err := installer.Install(context.Background(), version, server.URL+"/{{.Version}}", 0)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "exceeds allowed size")
+	})
+}
+
+func TestLocalInstaller_ExtractTimeout(t *testing.T) {
+	t.Parallel()
+	const version = "new-version"
+
+	dir := t.TempDir()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tgz, sum := testTGZ(t, version)
+		var out *bytes.Buffer
+		if strings.HasSuffix(r.URL.Path, "."+checksumType) {
+			out = bytes.NewBufferString(sum)
+		} else {
+			out = tgz
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(out.Len()))
+		_, err := io.Copy(w, out)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	installer := &LocalInstaller{
+		InstallDir:     dir,
+		HTTP:           http.DefaultClient,
+		Log:            slog.Default(),
+		ExtractTimeout: time.Nanosecond,
+	}
+	err := installer.Install(context.Background(), version, server.URL+"/{{.Version}}", 0)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "extraction timed out")
+
+	// The partial extraction must be cleaned up.
+	require.NoDirExists(t, filepath.Join(dir, version))
+}
+
+func TestLocalInstaller_TempDir(t *testing.T) {
+	t.Parallel()
+	const version = "new-version"
+
+	dir := t.TempDir()
+	tmpDir := filepath.Join(t.TempDir(), "scratch")
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tgz, sum := testTGZ(t, version)
+		var out *bytes.Buffer
+		if strings.HasSuffix(r.URL.Path, "."+checksumType) {
+			out = bytes.NewBufferString(sum)
+		} else {
+			out = tgz
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(out.Len()))
+		_, err := io.Copy(w, out)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	installer := &LocalInstaller{
+		InstallDir: dir,
+		TempDir:    tmpDir,
+		HTTP:       http.DefaultClient,
+		Log:        slog.Default(),
+	}
+	err := installer.Install(context.Background(), version, server.URL+"/{{.Version}}", 0)
+	require.NoError(t, err)
+
+	// The configured scratch directory is created and used instead of the
+	// default subdirectory of the install directory.
+	require.DirExists(t, tmpDir)
+	require.NoDirExists(t, filepath.Join(dir, tmpDirName))
+}
+
+func TestLocalInstaller_DownloadHeaders(t *testing.T) {
+	t.Parallel()
+	const version = "new-version"
+
+	dir := t.TempDir()
+
+	var authHeaders []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authHeaders = append(authHeaders, r.Header.Get("Authorization"))
+		tgz, sum := testTGZ(t, version)
+		var out *bytes.Buffer
+		if strings.HasSuffix(r.URL.Path, "."+checksumType) {
+			out = bytes.NewBufferString(sum)
+		} else {
+			out = tgz
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(out.Len()))
+		_, err := io.Copy(w, out)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	installer := &LocalInstaller{
+		InstallDir: dir,
+		HTTP:       http.DefaultClient,
+		DownloadHeaders: http.Header{
+			"Authorization": []string{"Bearer mirror-token"},
+		},
+		Log: slog.Default(),
+	}
+	err := installer.Install(context.Background(), version, server.URL+"/{{.Version}}", 0)
+	require.NoError(t, err)
+
+	// Both the checksum and tarball requests must include the header.
+	require.Equal(t, []string{"Bearer mirror-token", "Bearer mirror-token"}, authHeaders)
+}
+
+func TestLocalInstaller_Verify(t *testing.T) {
+	t.Parallel()
+	const version = "new-version"
+
+	dir := t.TempDir()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tgz, sum := testTGZ(t, version)
+		var out *bytes.Buffer
+		if strings.HasSuffix(r.URL.Path, "."+checksumType) {
+			out = bytes.NewBufferString(sum)
+		} else {
+			out = tgz
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(out.Len()))
+		_, err := io.Copy(w, out)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	installer := &LocalInstaller{
+		InstallDir: dir,
+		HTTP:       http.DefaultClient,
+		Log:        slog.Default(),
+	}
+	ctx := context.Background()
+	err := installer.Install(ctx, version, server.URL+"/{{.Version}}", 0)
+	require.NoError(t, err)
+
+	// An intact installation verifies.
+	err = installer.Verify(ctx, version)
+	require.NoError(t, err)
+
+	// A modified file fails verification with a typed error naming the file.
+	binPath := filepath.Join(dir, version, "bin", "teleport")
+	err = os.WriteFile(binPath, []byte("corrupt"), os.ModePerm)
+	require.NoError(t, err)
+	err = installer.Verify(ctx, version)
+	var verifyErr *VerifyError
+	require.ErrorAs(t, err, &verifyErr)
+	require.Equal(t, version, verifyErr.Version)
+	require.Equal(t, "bin/teleport", verifyErr.Path)
+
+	// A missing file also fails verification.
+	err = os.WriteFile(binPath, []byte(version), os.ModePerm)
+	require.NoError(t, err)
+	err = installer.Verify(ctx, version)
+	require.NoError(t, err)
+	err = os.Remove(binPath)
+	require.NoError(t, err)
+	err = installer.Verify(ctx, version)
+	require.ErrorAs(t, err, &verifyErr)
+	require.Equal(t, "bin/teleport", verifyErr.Path)
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestLocalInstaller_VerifyStaticBinaries(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		libs    []string // nil -> statically linked sample
+		allowed []string
+		elf     bool
+
+		errMatch string
+	}{
+		{
+			name: "statically linked",
+			elf:  true,
+		},
+		{
+			name:     "dynamically linked",
+			libs:     []string{"libc.so.6"},
+			elf:      true,
+			errMatch: `requires dynamic library "libc.so.6"`,
+		},
+		{
+			name:    "dynamically linked against allowed libraries",
+			libs:    []string{"libc.so.6", "libm.so.6"},
+			allowed: []string{"libc.so.6", "libm.so.6"},
+			elf:     true,
+		},
+		{
+			name:     "dynamically linked against disallowed library",
+			libs:     []string{"libc.so.6", "libfoo.so.1"},
+			allowed:  []string{"libc.so.6"},
+			elf:      true,
+			errMatch: `requires dynamic library "libfoo.so.1"`,
+		},
+		{
+			name: "non-ELF binary skipped",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			binDir := filepath.Join(dir, "bin")
+			require.NoError(t, os.MkdirAll(binDir, 0755))
+			bin := []byte("not an ELF binary")
+			if tt.elf {
+				bin = testELF(t, tt.libs)
+			}
+			err := os.WriteFile(filepath.Join(binDir, "teleport"), bin, 0755)
+			require.NoError(t, err)
+
+			installer := &LocalInstaller{
+				InstallDir:          dir,
+				Log:                 slog.Default(),
+				VerifyStaticLinking: true,
+				AllowedLibraries:    tt.allowed,
+			}
+			err = installer.verifyStaticBinaries(context.Background(), dir)
+			if tt.errMatch != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.errMatch)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+// testELF returns a minimal 64-bit little-endian ELF binary header with
+// DT_NEEDED entries for the provided library sonames.
+// A nil libs slice results in a statically linked sample without a dynamic section.
+func testELF(t *testing.T, libs []string) []byte {
+	t.Helper()
+
+	// .dynstr: NUL-prefixed soname string table
+	var dynstr bytes.Buffer
+	dynstr.WriteByte(0)
+	offsets := make([]uint64, len(libs))
+	for i, lib := range libs {
+		offsets[i] = uint64(dynstr.Len())
+		dynstr.WriteString(lib)
+		dynstr.WriteByte(0)
+	}
+
+	// .dynamic: DT_NEEDED entries terminated by DT_NULL
+	var dyn bytes.Buffer
+	for _, off := range offsets {
+		err := binary.Write(&dyn, binary.LittleEndian, elf.Dyn64{Tag: int64(elf.DT_NEEDED), Val: off})
+		require.NoError(t, err)
+	}
+	err := binary.Write(&dyn, binary.LittleEndian, elf.Dyn64{Tag: int64(elf.DT_NULL)})
+	require.NoError(t, err)
+
+	const ehSize = 64
+	dynstrOff := uint64(ehSize)
+	sections := []elf.Section64{
+		{}, // SHN_UNDEF
+		{Name: 1, Type: uint32(elf.SHT_STRTAB), Off: dynstrOff, Size: uint64(dynstr.Len()), Addralign: 1},
+	}
+	shstr := []byte("\x00.dynstr\x00.shstrtab\x00")
+	shstrName := uint32(9)
+	shstrOff := dynstrOff + uint64(dynstr.Len())
+	if libs != nil {
+		// The statically linked sample omits the dynamic section entirely.
+		shstr = []byte("\x00.dynstr\x00.dynamic\x00.shstrtab\x00")
+		shstrName = 18
+		dynOff := shstrOff
+		shstrOff += uint64(dyn.Len())
+		sections = append(sections, elf.Section64{
+			Name: 9, Type: uint32(elf.SHT_DYNAMIC), Off: dynOff, Size: uint64(dyn.Len()), Link: 1, Entsize: 16, Addralign: 8,
+		})
+	}
+	sections = append(sections, elf.Section64{
+		Name: shstrName, Type: uint32(elf.SHT_STRTAB), Off: shstrOff, Size: uint64(len(shstr)), Addralign: 1,
+	})
+	shOff := shstrOff + uint64(len(shstr))
+
+	hdr := elf.Header64{
+		Ident: [16]byte{
+			0x7f, 'E', 'L', 'F',
+			byte(elf.ELFCLASS64), byte(elf.ELFDATA2LSB), byte(elf.EV_CURRENT),
+		},
+		Type:      uint16(elf.ET_EXEC),
+		Machine:   uint16(elf.EM_X86_64),
+		Version:   uint32(elf.EV_CURRENT),
+		Shoff:     shOff,
+		Ehsize:    ehSize,
+		Shentsize: 64,
+		Shnum:     uint16(len(sections)),
+		Shstrndx:  uint16(len(sections) - 1),
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, binary.Write(&buf, binary.LittleEndian, hdr))
+	_, err = buf.Write(dynstr.Bytes())
+	require.NoError(t, err)
+	if libs != nil {
+		_, err = buf.Write(dyn.Bytes())
+		require.NoError(t, err)
+	}
+	_, err = buf.Write(shstr)
+	require.NoError(t, err)
+	require.NoError(t, binary.Write(&buf, binary.LittleEndian, sections))
+	return buf.Bytes()
+}
+
 func testTGZ(t *testing.T, version string) (tgz *bytes.Buffer, shasum string) {
 	t.Helper()
 
@@ -420,6 +1039,72 @@ func TestLocalInstaller_Link(t *testing.T) {
 	}
 }
 
+func TestLocalInstaller_LinkServiceBinaryCheck(t *testing.T) {
+	t.Parallel()
+	const version = "new-version"
+
+	tests := []struct {
+		name string
+		unit string // references LINKDIR, replaced with the link directory
+
+		errMatch string
+	}{
+		{
+			name: "all referenced binaries linked",
+			unit: "[Service]\n" +
+				"ExecStartPre=-LINKDIR/bin/teleport check\n" +
+				"ExecStart=LINKDIR/bin/teleport start --config=/etc/teleport.yaml\n",
+		},
+		{
+			name: "missing referenced binary",
+			unit: "[Service]\n" +
+				"ExecStart=LINKDIR/bin/teleport start\n" +
+				"ExecReload=LINKDIR/bin/missing reload\n",
+
+			errMatch: `binary "LINKDIR/bin/missing" referenced by systemd unit`,
+		},
+		{
+			name: "non-absolute references ignored",
+			unit: "[Service]\n" +
+				"ExecStart=!!LINKDIR/bin/teleport start\n" +
+				"ExecStop=${BINARY} stop\n",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			versionsDir := t.TempDir()
+			versionDir := filepath.Join(versionsDir, version)
+			linkDir := t.TempDir()
+
+			err := os.MkdirAll(filepath.Join(versionDir, "bin"), 0755)
+			require.NoError(t, err)
+			err = os.WriteFile(filepath.Join(versionDir, "bin", "teleport"), []byte(version), os.ModePerm)
+			require.NoError(t, err)
+			err = os.MkdirAll(filepath.Dir(filepath.Join(versionDir, servicePath)), 0755)
+			require.NoError(t, err)
+			unit := strings.ReplaceAll(tt.unit, "LINKDIR", linkDir)
+			err = os.WriteFile(filepath.Join(versionDir, servicePath), []byte(unit), os.ModePerm)
+			require.NoError(t, err)
+
+			installer := &LocalInstaller{
+				InstallDir:     versionsDir,
+				LinkBinDir:     filepath.Join(linkDir, "bin"),
+				LinkServiceDir: filepath.Join(linkDir, "lib/systemd/system"),
+				Log:            slog.Default(),
+			}
+			_, err = installer.Link(context.Background(), version)
+			if tt.errMatch != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), strings.ReplaceAll(tt.errMatch, "LINKDIR", linkDir))
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
 func TestLocalInstaller_Remove(t *testing.T) {
 	t.Parallel()
 	const version = "existing-version"
@@ -551,7 +1236,7 @@ func TestLocalInstaller_List(t *testing.T) {
 	installDir := t.TempDir()
 	versions := []string{"v1", "v2"}
 
-	for _, d := range versions {
+	for _, d := range append(versions, tmpDirName) {
 		err := os.Mkdir(filepath.Join(installDir, d), os.ModePerm)
 		require.NoError(t, err)
 	}
@@ -568,3 +1253,192 @@ func TestLocalInstaller_List(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, []string{"v1", "v2"}, versions)
 }
+
+func TestLocalInstaller_Cleanup(t *testing.T) {
+	installDir := t.TempDir()
+	tmpDir := filepath.Join(installDir, tmpDirName)
+	err := os.Mkdir(tmpDir, os.ModePerm)
+	require.NoError(t, err)
+
+	old := time.Now().Add(-2 * cleanupAge)
+	files := []struct {
+		name  string
+		stale bool
+
+		removed bool
+	}{
+		{name: partialPrefix + "1234", stale: true, removed: true},
+		{name: partialPrefix + "5678"}, // possibly in-progress
+		{name: "unrelated", stale: true},
+	}
+	for _, f := range files {
+		path := filepath.Join(tmpDir, f.name)
+		err := os.WriteFile(path, []byte("partial"), os.ModePerm)
+		require.NoError(t, err)
+		if f.stale {
+			err = os.Chtimes(path, old, old)
+			require.NoError(t, err)
+		}
+	}
+
+	installer := &LocalInstaller{
+		InstallDir: installDir,
+		Log:        slog.Default(),
+	}
+	err = installer.Cleanup(context.Background())
+	require.NoError(t, err)
+
+	for _, f := range files {
+		_, err := os.Stat(filepath.Join(tmpDir, f.name))
+		if f.removed {
+			require.ErrorIs(t, err, os.ErrNotExist, f.name)
+		} else {
+			require.NoError(t, err, f.name)
+		}
+	}
+
+	// A missing scratch directory is not an error.
+	installer.TempDir = filepath.Join(installDir, "missing")
+	err = installer.Cleanup(context.Background())
+	require.NoError(t, err)
+}
+
+func TestLocalInstaller_Unlink(t *testing.T) {
+	const version = "new-version"
+
+	installDir := t.TempDir()
+	linkDir := t.TempDir()
+	versionDir := filepath.Join(installDir, version)
+
+	for _, p := range []string{
+		filepath.Join(versionDir, "bin", "teleport"),
+		filepath.Join(versionDir, "bin", "tsh"),
+		filepath.Join(versionDir, servicePath),
+	} {
+		err := os.MkdirAll(filepath.Dir(p), os.ModePerm)
+		require.NoError(t, err)
+		err = os.WriteFile(p, []byte(version), os.ModePerm)
+		require.NoError(t, err)
+	}
+
+	installer := &LocalInstaller{
+		InstallDir:     installDir,
+		LinkBinDir:     filepath.Join(linkDir, "bin"),
+		LinkServiceDir: filepath.Join(linkDir, "lib", "systemd", "system"),
+		Log:            slog.Default(),
+	}
+	ctx := context.Background()
+
+	// Unlinking before linking is not needed.
+	err := installer.Unlink(ctx, version)
+	require.ErrorIs(t, err, ErrNotNeeded)
+
+	_, err = installer.Link(ctx, version)
+	require.NoError(t, err)
+
+	// Unlinking a different version leaves the links untouched.
+	otherDir := filepath.Join(installDir, "other-version")
+	err = os.MkdirAll(filepath.Join(otherDir, "bin"), os.ModePerm)
+	require.NoError(t, err)
+	err = os.WriteFile(filepath.Join(otherDir, "bin", "teleport"), []byte("other"), os.ModePerm)
+	require.NoError(t, err)
+	err = installer.Unlink(ctx, "other-version")
+	require.ErrorIs(t, err, ErrNotNeeded)
+	_, err = os.Lstat(filepath.Join(linkDir, "bin", "teleport"))
+	require.NoError(t, err)
+
+	// Unlinking the linked version removes all links.
+	err = installer.Unlink(ctx, version)
+	require.NoError(t, err)
+	for _, p := range []string{
+		filepath.Join(linkDir, "bin", "teleport"),
+		filepath.Join(linkDir, "bin", "tsh"),
+		filepath.Join(linkDir, "lib", "systemd", "system", "teleport.service"),
+	} {
+		_, err := os.Lstat(p)
+		require.ErrorIs(t, err, os.ErrNotExist)
+	}
+
+	// Unlink is idempotent.
+	err = installer.Unlink(ctx, version)
+	require.ErrorIs(t, err, ErrNotNeeded)
+}
+
+func TestLocalInstaller_LinkedVersion(t *testing.T) {
+	installDir := t.TempDir()
+	linkBinDir := filepath.Join(t.TempDir(), "bin")
+	versionDir := filepath.Join(installDir, "16.3.0")
+
+	err := os.MkdirAll(filepath.Join(versionDir, "bin"), os.ModePerm)
+	require.NoError(t, err)
+	err = os.WriteFile(filepath.Join(versionDir, "bin", "teleport"), []byte("binary"), os.ModePerm)
+	require.NoError(t, err)
+	err = os.MkdirAll(linkBinDir, os.ModePerm)
+	require.NoError(t, err)
+
+	installer := &LocalInstaller{
+		InstallDir: installDir,
+		LinkBinDir: linkBinDir,
+		Log:        slog.Default(),
+	}
+	ctx := context.Background()
+
+	// No link present.
+	version, err := installer.LinkedVersion(ctx)
+	require.NoError(t, err)
+	require.Empty(t, version)
+
+	// Link to an installed version.
+	err = os.Symlink(filepath.Join(versionDir, "bin", "teleport"), filepath.Join(linkBinDir, "teleport"))
+	require.NoError(t, err)
+	version, err = installer.LinkedVersion(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "16.3.0", version)
+
+	// Link pointing outside of the installation directory.
+	outsideBin := filepath.Join(t.TempDir(), "bin", "teleport")
+	err = os.MkdirAll(filepath.Dir(outsideBin), os.ModePerm)
+	require.NoError(t, err)
+	err = os.WriteFile(outsideBin, []byte("binary"), os.ModePerm)
+	require.NoError(t, err)
+	err = os.Remove(filepath.Join(linkBinDir, "teleport"))
+	require.NoError(t, err)
+	err = os.Symlink(outsideBin, filepath.Join(linkBinDir, "teleport"))
+	require.NoError(t, err)
+	version, err = installer.LinkedVersion(ctx)
+	require.NoError(t, err)
+	require.Empty(t, version)
+
+	// Non-linked install of Teleport.
+	err = os.Remove(filepath.Join(linkBinDir, "teleport"))
+	require.NoError(t, err)
+	err = os.WriteFile(filepath.Join(linkBinDir, "teleport"), []byte("binary"), os.ModePerm)
+	require.NoError(t, err)
+	version, err = installer.LinkedVersion(ctx)
+	require.NoError(t, err)
+	require.Empty(t, version)
+}
+
+func TestLocalInstaller_Stat(t *testing.T) {
+	installDir := t.TempDir()
+	versionDir := filepath.Join(installDir, "16.3.0")
+
+	err := os.MkdirAll(filepath.Join(versionDir, "bin"), os.ModePerm)
+	require.NoError(t, err)
+	err = os.WriteFile(filepath.Join(versionDir, "bin", "teleport"), []byte("1234567890"), os.ModePerm)
+	require.NoError(t, err)
+	err = os.WriteFile(filepath.Join(versionDir, flagsType), []byte("FIPS"), os.ModePerm)
+	require.NoError(t, err)
+
+	installer := &LocalInstaller{
+		InstallDir: installDir,
+		Log:        slog.Default(),
+	}
+	info, err := installer.Stat(context.Background(), "16.3.0")
+	require.NoError(t, err)
+	require.Equal(t, InstallInfo{
+		Version: "16.3.0",
+		Flags:   FlagFIPS,
+		Size:    14, // bin/teleport + flags marker
+	}, info)
+}
@@ -29,21 +29,55 @@ import (
 	"github.com/gravitational/trace"
 )
 
+// RestartMode specifies how SystemdService.Reload restarts the service.
+type RestartMode int
+
+const (
+	// RestartModeGraceful attempts a graceful reload (systemctl reload)
+	// before falling back to a hard restart.
+	RestartModeGraceful RestartMode = iota
+	// RestartModeHard skips the graceful reload and always performs a full
+	// restart (systemctl try-restart). Required for changes that a graceful
+	// reload cannot apply, such as a binary swap for services that do not
+	// re-exec on reload.
+	RestartModeHard
+)
+
 // SystemdService manages a Teleport systemd service.
 type SystemdService struct {
 	// ServiceName specifies the systemd service name.
 	ServiceName string
+	// Mode specifies whether Reload attempts a graceful reload before a hard
+	// restart (default), or always performs a hard restart.
+	// The updater re-runs its health check after Reload in either mode.
+	Mode RestartMode
+	// UnitPath optionally specifies the path to the systemd unit file for the
+	// service. The unit file is installed when a Teleport version is linked.
+	// If set, Sync returns ErrNoUnit when the unit file is missing, instead of
+	// a generic systemctl failure.
+	UnitPath string
 	// Log contains a logger.
 	Log *slog.Logger
 }
 
 // Reload a systemd service.
-// Attempts a graceful reload before a hard restart.
+// Attempts a graceful reload before a hard restart, unless RestartModeHard is
+// set, in which case the service is always restarted.
+// Reloads are not interruptible: once systemctl is invoked, the reload runs to
+// completion even if ctx is canceled (e.g., by SIGTERM), so that the host is
+// never left half-reloaded. Canceling ctx before the reload starts aborts the
+// operation with the context's error.
 // See Process interface for more details.
 func (s SystemdService) Reload(ctx context.Context) error {
+	// Exit early if the updater was interrupted before the reload started.
+	if err := ctx.Err(); err != nil {
+		return trace.Wrap(err)
+	}
 	if err := s.checkSystem(ctx); err != nil {
 		return trace.Wrap(err)
 	}
+	// Detach from cancellation signals so an in-flight reload always completes.
+	ctx = context.WithoutCancel(ctx)
 	// Command error codes < 0 indicate that we are unable to run the command.
 	// Errors from s.systemctl are logged along with stderr and stdout (debug only).
 
@@ -58,6 +92,15 @@ func (s SystemdService) Reload(ctx context.Context) error {
 		s.Log.WarnContext(ctx, "Teleport systemd service not running.")
 		return trace.Wrap(ErrNotNeeded)
 	}
+	// Restart the service without attempting a reload, if configured.
+	if s.Mode == RestartModeHard {
+		code = s.systemctl(ctx, slog.LevelError, "try-restart", s.ServiceName)
+		if code != 0 {
+			return trace.Errorf("hard restart of Teleport systemd service failed")
+		}
+		s.Log.InfoContext(ctx, "Teleport restarted. Connections potentially dropped.")
+		return nil
+	}
 	// Attempt graceful reload of running service.
 	code = s.systemctl(ctx, slog.LevelError, "reload", s.ServiceName)
 	switch {
@@ -82,6 +125,17 @@ func (s SystemdService) Reload(ctx context.Context) error {
 // Sync systemd service configuration by running systemctl daemon-reload.
 // See Process interface for more details.
 func (s SystemdService) Sync(ctx context.Context) error {
+	// Verify that the unit file is installed before invoking systemctl, so
+	// that a fresh install without a linked version produces an actionable
+	// error instead of a generic systemctl failure.
+	if s.UnitPath != "" {
+		if _, err := os.Stat(s.UnitPath); errors.Is(err, os.ErrNotExist) {
+			s.Log.ErrorContext(ctx, "Systemd unit file for service is missing. The unit file is installed when a Teleport version is linked.", "unit_path", s.UnitPath)
+			return trace.Wrap(ErrNoUnit)
+		} else if err != nil {
+			return trace.Wrap(err)
+		}
+	}
 	if err := s.checkSystem(ctx); err != nil {
 		return trace.Wrap(err)
 	}
@@ -92,6 +146,30 @@ func (s SystemdService) Sync(ctx context.Context) error {
 	return nil
 }
 
+// Stop a systemd service.
+// See Process interface for more details.
+func (s SystemdService) Stop(ctx context.Context) error {
+	if err := s.checkSystem(ctx); err != nil {
+		return trace.Wrap(err)
+	}
+	// Detach from cancellation signals so an in-flight stop always completes.
+	ctx = context.WithoutCancel(ctx)
+	code := s.systemctl(ctx, slog.LevelDebug, "is-active", "--quiet", s.ServiceName)
+	switch {
+	case code < 0:
+		return trace.Errorf("unable to determine if systemd service is active")
+	case code > 0:
+		s.Log.InfoContext(ctx, "Teleport systemd service already stopped.")
+		return trace.Wrap(ErrNotNeeded)
+	}
+	code = s.systemctl(ctx, slog.LevelError, "stop", s.ServiceName)
+	if code != 0 {
+		return trace.Errorf("unable to stop Teleport systemd service")
+	}
+	s.Log.InfoContext(ctx, "Teleport systemd service stopped.")
+	return nil
+}
+
 // checkSystem returns an error if the system is not compatible with this process manager.
 func (s SystemdService) checkSystem(ctx context.Context) error {
 	_, err := os.Stat("/run/systemd/system")
@@ -22,6 +22,8 @@ import (
 	"bytes"
 	"context"
 	"log/slog"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -62,6 +64,45 @@ func TestLineLogger(t *testing.T) {
 	require.Equal(t, "msg=ab\nmsg=c\nmsg=de\nmsg=f\nmsg=gh\nmsg=i\nmsg=j\n", out.String())
 }
 
+func TestSystemdService_ReloadInterrupted(t *testing.T) {
+	t.Parallel()
+
+	// A context canceled before the reload starts (e.g., SIGTERM between
+	// operations) must abort the reload before any systemctl invocation,
+	// leaving the service untouched.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	s := SystemdService{
+		ServiceName: "teleport.service",
+		Log:         slog.Default(),
+	}
+	err := s.Reload(ctx)
+	require.ErrorIs(t, err, context.Canceled)
+}
+
+func TestSystemdService_SyncMissingUnit(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	unitPath := filepath.Join(dir, "teleport.service")
+	s := SystemdService{
+		ServiceName: "teleport.service",
+		UnitPath:    unitPath,
+		Log:         slog.Default(),
+	}
+	ctx := context.Background()
+
+	// A missing unit file results in a typed error, not a generic systemctl failure.
+	err := s.Sync(ctx)
+	require.ErrorIs(t, err, ErrNoUnit)
+
+	// Once the unit file is installed (by linking a version), Sync proceeds.
+	err = os.WriteFile(unitPath, []byte("[Unit]"), 0644)
+	require.NoError(t, err)
+	err = s.Sync(ctx)
+	require.NotErrorIs(t, err, ErrNoUnit)
+}
+
 func msgOnly(_ []string, a slog.Attr) slog.Attr {
 	switch a.Key {
 	case "time", "level":
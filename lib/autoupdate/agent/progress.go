@@ -0,0 +1,136 @@
+/*
+ * Teleport
+ * Copyright (C) 2024  Gravitational, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package agent
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"time"
+)
+
+const (
+	// progressLogInterval specifies how often download progress is logged.
+	progressLogInterval = 5 * time.Second
+	// progressCallbackInterval specifies how often the progress callback is invoked.
+	// Throttling ensures the callback does not meaningfully slow the copy.
+	progressCallbackInterval = 100 * time.Millisecond
+	// progressRateSmoothing specifies the weight of the previous rolling average
+	// when a new throughput sample is observed (exponential smoothing).
+	progressRateSmoothing = 0.7
+)
+
+// progressETA estimates the time remaining for a transfer using a rolling
+// average of observed throughput.
+type progressETA struct {
+	total      int64
+	downloaded int64
+	rate       float64 // smoothed bytes per second
+	lastTime   time.Time
+}
+
+// newProgressETA returns a progressETA for a transfer of total bytes starting at now.
+// Pass a negative total if the total size is unknown.
+func newProgressETA(total int64, now time.Time) *progressETA {
+	return &progressETA{
+		total:    total,
+		lastTime: now,
+	}
+}
+
+// advance records that n additional bytes were transferred by now,
+// updating the rolling average throughput.
+func (p *progressETA) advance(n int64, now time.Time) {
+	p.downloaded += n
+	elapsed := now.Sub(p.lastTime)
+	if elapsed <= 0 {
+		return
+	}
+	sample := float64(n) / elapsed.Seconds()
+	if p.rate == 0 {
+		p.rate = sample
+	} else {
+		p.rate = progressRateSmoothing*p.rate + (1-progressRateSmoothing)*sample
+	}
+	p.lastTime = now
+}
+
+// eta returns the estimated time remaining for the transfer.
+// It returns -1 if the total size is unknown or no throughput has been observed.
+func (p *progressETA) eta() time.Duration {
+	if p.total < 0 || p.rate == 0 {
+		return -1
+	}
+	remaining := p.total - p.downloaded
+	if remaining <= 0 {
+		return 0
+	}
+	return time.Duration(float64(remaining) / p.rate * float64(time.Second))
+}
+
+// progressWriter logs transfer progress and ETA as bytes are written through it,
+// and periodically invokes an optional progress callback.
+type progressWriter struct {
+	ctx      context.Context
+	log      *slog.Logger
+	eta      *progressETA
+	report   func(downloaded, total int64)
+	lastLog  time.Time
+	lastCall time.Time
+}
+
+// newProgressWriter returns an io.Writer that tracks progress of a transfer
+// of total bytes, logging throughput-based ETA at most every progressLogInterval.
+// If report is non-nil, it is invoked with the current progress at most every
+// progressCallbackInterval, with total set to -1 when the total size is unknown.
+func newProgressWriter(ctx context.Context, log *slog.Logger, total int64, report func(downloaded, total int64)) io.Writer {
+	now := time.Now()
+	return &progressWriter{
+		ctx:     ctx,
+		log:     log,
+		eta:     newProgressETA(total, now),
+		report:  report,
+		lastLog: now,
+	}
+}
+
+func (w *progressWriter) Write(p []byte) (int, error) {
+	now := time.Now()
+	w.eta.advance(int64(len(p)), now)
+	if now.Sub(w.lastLog) >= progressLogInterval {
+		w.lastLog = now
+		args := []any{"downloaded", w.eta.downloaded}
+		if w.eta.total >= 0 {
+			args = append(args, "total", w.eta.total)
+		}
+		if eta := w.eta.eta(); eta >= 0 {
+			args = append(args, "eta", eta.Round(time.Second))
+		}
+		w.log.InfoContext(w.ctx, "Download in progress.", args...)
+	}
+	if w.report != nil && now.Sub(w.lastCall) >= progressCallbackInterval {
+		w.lastCall = now
+		total := w.eta.total
+		if total < 0 {
+			total = -1
+		}
+		w.report(w.eta.downloaded, total)
+	}
+	return len(p), nil
+}
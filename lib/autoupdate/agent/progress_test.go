@@ -0,0 +1,94 @@
+/*
+ * Teleport
+ * Copyright (C) 2024  Gravitational, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package agent
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProgressETA(t *testing.T) {
+	t.Parallel()
+
+	start := time.Now()
+	p := newProgressETA(1000, start)
+
+	// No throughput observed yet.
+	require.Equal(t, time.Duration(-1), p.eta())
+
+	// 100 bytes per second, steady stream: ETA should decrease as bytes arrive.
+	var last time.Duration
+	for i := 1; i <= 9; i++ {
+		p.advance(100, start.Add(time.Duration(i)*time.Second))
+		eta := p.eta()
+		require.Greater(t, eta, time.Duration(0))
+		if i > 1 {
+			require.Less(t, eta, last)
+		}
+		last = eta
+	}
+	// ~100 bytes left at ~100 bytes/sec.
+	require.InDelta(t, time.Second, p.eta(), float64(200*time.Millisecond))
+
+	// Completed transfers report zero remaining.
+	p.advance(100, start.Add(10*time.Second))
+	require.Equal(t, time.Duration(0), p.eta())
+}
+
+func TestProgressWriter_Callback(t *testing.T) {
+	t.Parallel()
+
+	type call struct {
+		downloaded, total int64
+	}
+	var calls []call
+	w := newProgressWriter(context.Background(), slog.Default(), 1000, func(downloaded, total int64) {
+		calls = append(calls, call{downloaded, total})
+	})
+
+	// The first write reports immediately; rapid subsequent writes are throttled.
+	for i := 0; i < 3; i++ {
+		n, err := w.Write(make([]byte, 100))
+		require.NoError(t, err)
+		require.Equal(t, 100, n)
+	}
+	require.Equal(t, []call{{100, 1000}}, calls)
+
+	// Unknown totals are reported as -1.
+	calls = nil
+	w = newProgressWriter(context.Background(), slog.Default(), -1, func(downloaded, total int64) {
+		calls = append(calls, call{downloaded, total})
+	})
+	_, err := w.Write(make([]byte, 100))
+	require.NoError(t, err)
+	require.Equal(t, []call{{100, -1}}, calls)
+}
+
+func TestProgressETA_UnknownTotal(t *testing.T) {
+	t.Parallel()
+
+	start := time.Now()
+	p := newProgressETA(-1, start)
+	p.advance(100, start.Add(time.Second))
+	require.Equal(t, time.Duration(-1), p.eta())
+}
@@ -19,18 +19,27 @@
 package agent
 
 import (
+	"bytes"
 	"context"
+	"crypto/subtle"
 	"crypto/tls"
 	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"io/fs"
 	"log/slog"
 	"net/http"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"slices"
 	"strings"
+	"syscall"
 	"time"
 
+	"github.com/coreos/go-semver/semver"
 	"github.com/google/renameio/v2"
 	"github.com/gravitational/trace"
 	"gopkg.in/yaml.v3"
@@ -47,6 +56,16 @@ const (
 	// TODO(sclevine): This value is arbitrary and could be replaced by, e.g., min(1%, 200mb) in the future
 	//   to account for a range of disk sizes.
 	reservedFreeDisk = 10_000_000 // 10 MB
+	// defaultKeepVersions is the default number of installed versions to retain after updates.
+	defaultKeepVersions = 2
+	// failureCooldownBase is the cooldown enforced after the first failed
+	// scheduled update, doubled on each consecutive failure.
+	failureCooldownBase = 5 * time.Minute
+	// failureCooldownMax caps the cooldown enforced after repeated failures.
+	failureCooldownMax = 6 * time.Hour
+	// advisoryFindTimeout bounds the advisory proxy lookup used to verify
+	// install flags for forced versions, which may run on air-gapped hosts.
+	advisoryFindTimeout = 5 * time.Second
 )
 
 const (
@@ -54,8 +73,9 @@ const (
 	updateConfigName = "update.yaml"
 
 	// UpdateConfig metadata
-	updateConfigVersion = "v1"
-	updateConfigKind    = "update_config"
+	updateConfigVersion   = "v2"
+	updateConfigVersionV1 = "v1"
+	updateConfigKind      = "update_config"
 )
 
 // UpdateConfig describes the update.yaml file schema.
@@ -78,16 +98,48 @@ type UpdateSpec struct {
 	Group string `yaml:"group"`
 	// URLTemplate for the Teleport tgz download URL.
 	URLTemplate string `yaml:"url_template"`
+	// URLTemplates contains additional mirror URL templates, tried in order after URLTemplate.
+	URLTemplates []string `yaml:"url_templates,omitempty"`
+	// AllowedVersions restricts installs to the listed versions when non-empty.
+	AllowedVersions []string `yaml:"allowed_versions,omitempty"`
 	// Enabled controls whether auto-updates are enabled.
 	Enabled bool `yaml:"enabled"`
 }
 
+// urlTemplates returns the ordered list of download URL templates to attempt.
+// The primary URLTemplate is tried first, followed by any mirror templates.
+// The default CDN template is used if no templates are configured.
+func (s *UpdateSpec) urlTemplates() []string {
+	switch {
+	case s.URLTemplate != "":
+		return append([]string{s.URLTemplate}, s.URLTemplates...)
+	case len(s.URLTemplates) > 0:
+		return s.URLTemplates
+	}
+	return []string{cdnURITemplate}
+}
+
 // UpdateStatus describes the status field in update.yaml.
 type UpdateStatus struct {
 	// ActiveVersion is the currently active Teleport version.
 	ActiveVersion string `yaml:"active_version"`
 	// BackupVersion is the last working version of Teleport.
 	BackupVersion string `yaml:"backup_version"`
+	// ActiveSource is the resolved download URL (after template expansion)
+	// that installed the active version, recorded for provenance audits.
+	ActiveSource string `yaml:"active_source,omitempty"`
+	// ActiveFlags are the install flags (e.g., Enterprise, FIPS) of the
+	// currently active Teleport version.
+	ActiveFlags InstallFlags `yaml:"active_flags,flow,omitempty"`
+	// LastReload is the time of the last reload triggered by the updater.
+	// Only recorded when reload throttling is enabled.
+	LastReload time.Time `yaml:"last_reload,omitempty"`
+	// Failures is the number of consecutive failed update attempts.
+	// Reset on the first successful update.
+	Failures int `yaml:"failures,omitempty"`
+	// LastFailure is the time of the last failed update attempt.
+	// Recorded so that the failure cooldown survives process restarts.
+	LastFailure time.Time `yaml:"last_failure,omitempty"`
 }
 
 // NewLocalUpdater returns a new Updater that auto-updates local
@@ -99,6 +151,17 @@ func NewLocalUpdater(cfg LocalUpdaterConfig) (*Updater, error) {
 	if err != nil {
 		return nil, trace.Wrap(err)
 	}
+	if cfg.CAFile != "" {
+		// Containers and other restricted environments may lack system certs;
+		// a CA bundle file is a safer alternative than InsecureSkipVerify.
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, trace.Errorf("failed to read CA bundle %s: %w", cfg.CAFile, err)
+		}
+		if !certPool.AppendCertsFromPEM(pem) {
+			return nil, trace.Errorf("no certificates found in CA bundle %s", cfg.CAFile)
+		}
+	}
 	tr, err := libdefaults.Transport()
 	if err != nil {
 		return nil, trace.Wrap(err)
@@ -107,6 +170,18 @@ func NewLocalUpdater(cfg LocalUpdaterConfig) (*Updater, error) {
 		InsecureSkipVerify: cfg.InsecureSkipVerify,
 		RootCAs:            certPool,
 	}
+	if len(cfg.ProxyCAPins) > 0 {
+		if cfg.InsecureSkipVerify {
+			return nil, trace.Errorf("ProxyCAPins and InsecureSkipVerify are mutually exclusive")
+		}
+		if err := validatePins(cfg.ProxyCAPins); err != nil {
+			return nil, trace.Wrap(err)
+		}
+		// Disable standard chain verification and validate the presented
+		// chain against the configured SPKI pins instead.
+		tr.TLSClientConfig.InsecureSkipVerify = true
+		tr.TLSClientConfig.VerifyPeerCertificate = verifyPinnedCerts(cfg.ProxyCAPins)
+	}
 	client := &http.Client{
 		Transport: tr,
 		Timeout:   cfg.DownloadTimeout,
@@ -120,25 +195,62 @@ func NewLocalUpdater(cfg LocalUpdaterConfig) (*Updater, error) {
 	if cfg.VersionsDir == "" {
 		cfg.VersionsDir = filepath.Join(libdefaults.DataDir, "versions")
 	}
+	linkServiceDir := filepath.Join(cfg.LinkDir, "lib", "systemd", "system")
+	var extraProcesses []Process
+	for _, name := range cfg.ExtraServices {
+		extraProcesses = append(extraProcesses, &SystemdService{
+			ServiceName: name,
+			Mode:        cfg.RestartMode,
+			UnitPath:    filepath.Join(linkServiceDir, name),
+			Log:         cfg.Log,
+		})
+	}
 	return &Updater{
 		Log:                cfg.Log,
 		Pool:               certPool,
 		InsecureSkipVerify: cfg.InsecureSkipVerify,
 		ConfigPath:         filepath.Join(cfg.VersionsDir, updateConfigName),
+		InstallDir:         cfg.VersionsDir,
+		TeleportConfigPath: cfg.TeleportConfigPath,
 		Installer: &LocalInstaller{
-			InstallDir:     cfg.VersionsDir,
-			LinkBinDir:     filepath.Join(cfg.LinkDir, "bin"),
-			LinkServiceDir: filepath.Join(cfg.LinkDir, "lib", "systemd", "system"),
-			HTTP:           client,
-			Log:            cfg.Log,
+			InstallDir:      cfg.VersionsDir,
+			TempDir:         cfg.TempDir,
+			LinkBinDir:      filepath.Join(cfg.LinkDir, "bin"),
+			LinkServiceDir:  linkServiceDir,
+			HTTP:            client,
+			DownloadHeaders: cfg.DownloadHeaders,
+			AllowedHosts:    cfg.AllowedHosts,
+			MaxDownloadSize: cfg.MaxDownloadSize,
+			ExtractTimeout:  cfg.ExtractTimeout,
+			Log:             cfg.Log,
+			Progress:        cfg.Progress,
+			PeerCache:       cfg.PeerCache,
+
+			VerifyStaticLinking: cfg.VerifyStaticLinking,
+			AllowedLibraries:    cfg.AllowedLibraries,
 
 			ReservedFreeTmpDisk:     reservedFreeDisk,
 			ReservedFreeInstallDisk: reservedFreeDisk,
 		},
 		Process: &SystemdService{
 			ServiceName: "teleport.service",
+			Mode:        cfg.RestartMode,
+			UnitPath:    filepath.Join(linkServiceDir, "teleport.service"),
 			Log:         cfg.Log,
 		},
+		ExtraProcesses:     extraProcesses,
+		KeepVersions:       cfg.KeepVersions,
+		StrictVersionLimit: cfg.StrictVersionLimit,
+		PreInstall:         cfg.PreInstall,
+		PostInstall:        cfg.PostInstall,
+		PostReload:         cfg.PostReload,
+		MinReloadInterval:  cfg.MinReloadInterval,
+		ReportUpdates:      cfg.ReportUpdates,
+		ConfigOwnerCheck:   cfg.ConfigOwnerCheck,
+		LinkDriftPolicy:    cfg.LinkDriftPolicy,
+		EnableRetries:      cfg.EnableRetries,
+		HTTP:               client,
+		HealthCheck:        cfg.HealthCheck,
 	}, nil
 }
 
@@ -149,13 +261,108 @@ type LocalUpdaterConfig struct {
 	Log *slog.Logger
 	// InsecureSkipVerify turns off TLS certificate verification.
 	InsecureSkipVerify bool
+	// CAFile is the path to a PEM-encoded CA bundle appended to the system
+	// pool for proxy and download TLS, for environments without system certs.
+	// Defaults to the system pool only.
+	CAFile string
+	// ProxyCAPins contains SHA256 SPKI pins (e.g., "sha256:<hex>") for the proxy certificate chain.
+	// When set, the presented chain is validated against the pins instead of the system pool.
+	// Mutually exclusive with InsecureSkipVerify.
+	ProxyCAPins []string
 	// DownloadTimeout is a timeout for file download requests.
 	// Defaults to no timeout.
 	DownloadTimeout time.Duration
+	// ExtractTimeout bounds the tar extraction step of an install,
+	// independent of DownloadTimeout.
+	// Defaults to no timeout.
+	ExtractTimeout time.Duration
+	// DownloadHeaders are included on every download request (e.g., auth
+	// tokens for private mirrors). Header values are never logged.
+	DownloadHeaders http.Header
+	// AllowedHosts restricts downloads to URLs with the listed hostnames
+	// when non-empty, guarding against URL template tampering.
+	AllowedHosts []string
+	// MaxDownloadSize is the maximum size of the Teleport download, in bytes.
+	// Defaults to no limit.
+	MaxDownloadSize int64
 	// VersionsDir for installing Teleport (usually /var/lib/teleport/versions).
 	VersionsDir string
+	// TeleportConfigPath is the path to the agent's Teleport configuration
+	// file (usually /etc/teleport.yaml). When set, Enable falls back to the
+	// proxy address in this file if none is configured or passed as a flag.
+	// Only the proxy address is parsed from the file.
+	TeleportConfigPath string
+	// TempDir is the scratch directory for downloads before extraction.
+	// Defaults to a hidden subdirectory of VersionsDir, so that scratch space
+	// resides on the same filesystem as the installation.
+	TempDir string
 	// LinkDir for installing Teleport (usually /usr/local).
 	LinkDir string
+	// KeepVersions specifies the number of installed versions to retain after updates.
+	// Defaults to 2 (active and backup).
+	KeepVersions int
+	// StrictVersionLimit causes updates to fail if more than KeepVersions
+	// versions remain installed after cleanup, instead of logging a warning.
+	StrictVersionLimit bool
+	// PreInstall is an optional command (argv) run before a new version is
+	// installed (e.g., SELinux relabeling or other platform integration).
+	// The version and its installation path are provided in the environment.
+	// The operation fails if the command exits non-zero.
+	PreInstall []string
+	// PostInstall is an optional command (argv) run after a new version is
+	// installed, before it is linked and Teleport is reloaded.
+	// The operation fails if the command exits non-zero.
+	PostInstall []string
+	// PostReload is an optional command (argv) run after Teleport is
+	// successfully reloaded at the new version.
+	// The operation fails if the command exits non-zero.
+	PostReload []string
+	// MinReloadInterval is the minimum interval between reloads during scheduled updates.
+	// Scheduled updates that would reload sooner are deferred to avoid flapping.
+	// Defaults to no throttling.
+	MinReloadInterval time.Duration
+	// ReportUpdates enables best-effort reporting of update outcomes to the proxy.
+	ReportUpdates bool
+	// ExtraServices lists additional Teleport systemd services on the host.
+	// During updates, services are reloaded one at a time, with health
+	// verification between each, halting the rollout on the first failure.
+	ExtraServices []string
+	// RestartMode controls whether updates restart Teleport services with a
+	// graceful reload or a hard restart. Updates always swap the Teleport
+	// binary, so a hard restart is needed for services that do not re-exec on
+	// reload. The health check runs after the service restarts in either mode.
+	// Defaults to graceful reload.
+	RestartMode RestartMode
+	// ConfigOwnerCheck specifies how unsafe ownership or permissions on
+	// update.yaml are handled when the file is read.
+	// Defaults to no checking.
+	ConfigOwnerCheck OwnerCheckMode
+	// LinkDriftPolicy specifies how an active link that was externally
+	// repointed to a different installed version is handled.
+	// Defaults to logging a warning without correcting the discrepancy.
+	LinkDriftPolicy LinkDriftPolicy
+	// EnableRetries is the number of additional attempts made when Enable
+	// fails with a transient error. Installed artifacts are preserved between
+	// attempts, so retries do not re-download the Teleport tarball.
+	// Defaults to no retries.
+	EnableRetries int
+	// Progress is invoked periodically with download progress, if set.
+	// Total is -1 when the total download size is unknown.
+	Progress func(downloaded, total int64)
+	// PeerCache optionally serves archives already downloaded by other agents
+	// on the local network, which are tried before the download URL.
+	// Defaults to no peer cache.
+	PeerCache PeerSource
+	// VerifyStaticLinking refuses installs whose binaries depend on dynamic
+	// libraries not listed in AllowedLibraries.
+	VerifyStaticLinking bool
+	// AllowedLibraries lists dynamic libraries (sonames) that installed
+	// binaries may depend on when VerifyStaticLinking is set.
+	AllowedLibraries []string
+	// HealthCheck validates the agent after a reload, before the new version is committed.
+	// Defaults to no validation beyond Process.Reload if nil.
+	// See HTTPHealthCheck for a default implementation.
+	HealthCheck func(ctx context.Context) error
 }
 
 // Updater implements the agent-local logic for Teleport agent auto-updates.
@@ -168,10 +375,51 @@ type Updater struct {
 	InsecureSkipVerify bool
 	// ConfigPath contains the path to the agent auto-updates configuration.
 	ConfigPath string
+	// InstallDir contains each installed version of Teleport, named by version.
+	InstallDir string
+	// TeleportConfigPath is the path to the agent's Teleport configuration
+	// file, used as a fallback source for the proxy address during Enable.
+	TeleportConfigPath string
 	// Installer manages installations of the Teleport agent.
 	Installer Installer
 	// Process manages a running instance of Teleport.
 	Process Process
+	// ExtraProcesses lists additional Teleport services on the host.
+	// During updates, services are reloaded one at a time after Process,
+	// halting the rollout on the first failure.
+	ExtraProcesses []Process
+	// KeepVersions specifies the number of installed versions to retain after updates.
+	KeepVersions int
+	// StrictVersionLimit causes updates to fail if more than KeepVersions
+	// versions remain installed after cleanup, instead of logging a warning.
+	StrictVersionLimit bool
+	// PreInstall is an optional command (argv) run before a new version is installed.
+	PreInstall []string
+	// PostInstall is an optional command (argv) run after a new version is
+	// installed, before it is linked and Teleport is reloaded.
+	PostInstall []string
+	// PostReload is an optional command (argv) run after Teleport is
+	// successfully reloaded at the new version.
+	PostReload []string
+	// MinReloadInterval is the minimum interval between reloads during scheduled updates.
+	MinReloadInterval time.Duration
+	// ReportUpdates enables best-effort reporting of update outcomes to the proxy.
+	ReportUpdates bool
+	// ConfigOwnerCheck specifies how unsafe ownership or permissions on
+	// update.yaml are handled when the file is read.
+	ConfigOwnerCheck OwnerCheckMode
+	// LinkDriftPolicy specifies how an active link that was externally
+	// repointed to a different installed version is handled.
+	LinkDriftPolicy LinkDriftPolicy
+	// EnableRetries is the number of additional attempts made when Enable
+	// fails with a transient error.
+	EnableRetries int
+	// HTTP is an HTTP client for requests to the Teleport web API.
+	HTTP *http.Client
+	// HealthCheck validates the agent after a reload, before the new version is committed.
+	// If HealthCheck returns an error, the update is reverted.
+	// Optional; no additional validation occurs if nil.
+	HealthCheck func(ctx context.Context) error
 }
 
 // Installer provides an API for installing Teleport agents.
@@ -184,12 +432,33 @@ type Installer interface {
 	// Link must be idempotent.
 	// Link's revert function must be idempotent.
 	Link(ctx context.Context, version string) (revert func(context.Context) bool, err error)
+	// LinkedVersion returns the installed version that the system link
+	// currently points to, or an empty string if no installed version is linked.
+	LinkedVersion(ctx context.Context) (version string, err error)
+	// Unlink removes the system links for the specified version, if and only
+	// if they point to that version.
+	// Must return ErrNotNeeded if no links for the version are present.
+	// Unlink must be idempotent.
+	Unlink(ctx context.Context, version string) error
 	// List the installed versions of Teleport.
 	List(ctx context.Context) (versions []string, err error)
 	// Remove the Teleport agent at version.
 	// Must return ErrLinked if unable to remove due to being linked.
 	// Remove must be idempotent.
 	Remove(ctx context.Context, version string) error
+	// Verify the integrity of the installed Teleport agent at version.
+	// Must return an error wrapping *VerifyError identifying the file that
+	// failed verification.
+	Verify(ctx context.Context, version string) error
+	// CheckDisk verifies that the installation directories are writable and
+	// have sufficient free disk space for an install.
+	CheckDisk(ctx context.Context) error
+	// Stat returns details (install flags, size on disk) for the installed
+	// Teleport agent at version.
+	Stat(ctx context.Context, version string) (InstallInfo, error)
+	// Cleanup removes stale download artifacts left behind by interrupted
+	// installs. Cleanup must never remove artifacts of an in-progress install.
+	Cleanup(ctx context.Context) error
 }
 
 var (
@@ -199,8 +468,80 @@ var (
 	ErrNotNeeded = errors.New("not needed")
 	// ErrNotSupported is returned when the operation is not supported on the platform.
 	ErrNotSupported = errors.New("not supported on this platform")
+	// ErrNoUnit is returned when the systemd unit for the service is not installed.
+	ErrNoUnit = errors.New("systemd unit not installed")
 )
 
+// InstallError reports a failure to download or install a version of Teleport.
+// Install failures are usually transient (e.g., network errors) and safe to retry.
+type InstallError struct {
+	// Version of Teleport that failed to install.
+	Version string
+	// Err describes why the installation failed.
+	Err error
+}
+
+func (e *InstallError) Error() string {
+	return fmt.Sprintf("failed to install version %q: %v", e.Version, e.Err)
+}
+
+func (e *InstallError) Unwrap() error {
+	return e.Err
+}
+
+// LinkError reports a failure to link an installed version of Teleport into
+// the system location.
+type LinkError struct {
+	// Version of Teleport that failed to link.
+	Version string
+	// Err describes why the linking failed.
+	Err error
+}
+
+func (e *LinkError) Error() string {
+	return fmt.Sprintf("failed to link version %q: %v", e.Version, e.Err)
+}
+
+func (e *LinkError) Unwrap() error {
+	return e.Err
+}
+
+// SyncError reports a failure to validate process configuration for a version
+// of Teleport after linking. The installation is reverted before SyncError is
+// returned.
+type SyncError struct {
+	// Version of Teleport that failed configuration validation.
+	Version string
+	// Err describes why the validation failed.
+	Err error
+}
+
+func (e *SyncError) Error() string {
+	return fmt.Sprintf("failed to validate configuration for new version %q of Teleport: %v", e.Version, e.Err)
+}
+
+func (e *SyncError) Unwrap() error {
+	return e.Err
+}
+
+// ReloadError reports a failure to restart Teleport at a version after linking.
+// The installation is reverted before ReloadError is returned, but the failed
+// restart may have dropped connections, so callers should alert rather than retry.
+type ReloadError struct {
+	// Version of Teleport that failed to start.
+	Version string
+	// Err describes why the restart failed.
+	Err error
+}
+
+func (e *ReloadError) Error() string {
+	return fmt.Sprintf("failed to start new version %q of Teleport: %v", e.Version, e.Err)
+}
+
+func (e *ReloadError) Unwrap() error {
+	return e.Err
+}
+
 // Process provides an API for interacting with a running Teleport process.
 type Process interface {
 	// Reload must reload the Teleport process as gracefully as possible.
@@ -216,6 +557,11 @@ type Process interface {
 	// If the type implementing Process does not support the system process manager,
 	// Sync must return ErrNotSupported.
 	Sync(ctx context.Context) error
+	// Stop must stop the Teleport process.
+	// If the process is not running, Stop must return ErrNotNeeded.
+	// If the type implementing Process does not support the system process manager,
+	// Stop must return ErrNotSupported.
+	Stop(ctx context.Context) error
 }
 
 // InstallFlags sets flags for the Teleport installation
@@ -229,12 +575,91 @@ const (
 	FlagFIPS
 )
 
+// String returns the name of a single install flag.
+func (i InstallFlags) String() string {
+	switch i {
+	case FlagEnterprise:
+		return "Enterprise"
+	case FlagFIPS:
+		return "FIPS"
+	}
+	return ""
+}
+
+// Strings returns the names of the set install flags.
+func (i InstallFlags) Strings() []string {
+	var names []string
+	for _, flag := range []InstallFlags{FlagEnterprise, FlagFIPS} {
+		if i&flag != 0 {
+			names = append(names, flag.String())
+		}
+	}
+	return names
+}
+
+// MarshalJSON marshals InstallFlags as a list of flag names.
+func (i InstallFlags) MarshalJSON() ([]byte, error) {
+	return json.Marshal(i.Strings())
+}
+
+// UnmarshalJSON unmarshals InstallFlags from a list of flag names.
+func (i *InstallFlags) UnmarshalJSON(b []byte) error {
+	var names []string
+	if err := json.Unmarshal(b, &names); err != nil {
+		return trace.Wrap(err)
+	}
+	flags, err := parseInstallFlags(names)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	*i = flags
+	return nil
+}
+
+// MarshalYAML marshals InstallFlags as a list of flag names.
+func (i InstallFlags) MarshalYAML() (any, error) {
+	return i.Strings(), nil
+}
+
+// UnmarshalYAML unmarshals InstallFlags from a list of flag names.
+func (i *InstallFlags) UnmarshalYAML(n *yaml.Node) error {
+	var names []string
+	if err := n.Decode(&names); err != nil {
+		return trace.Wrap(err)
+	}
+	flags, err := parseInstallFlags(names)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	*i = flags
+	return nil
+}
+
+// parseInstallFlags returns the InstallFlags identified by the provided names.
+func parseInstallFlags(names []string) (InstallFlags, error) {
+	var flags InstallFlags
+	for _, name := range names {
+		switch name {
+		case FlagEnterprise.String():
+			flags |= FlagEnterprise
+		case FlagFIPS.String():
+			flags |= FlagFIPS
+		default:
+			return 0, trace.Errorf("unknown install flag %q", name)
+		}
+	}
+	return flags, nil
+}
+
 // OverrideConfig contains overrides for individual update operations.
 // If validated, these overrides may be persisted to disk.
 type OverrideConfig struct {
 	// Proxy address, scheme and port optional.
 	// Overrides existing value if specified.
 	Proxy string
+	// ProxyPersist specifies whether an overridden Proxy is persisted to
+	// update.yaml or used only for the current run.
+	ProxyPersist ProxyPersistMode
 	// Group identifier for updates (e.g., staging)
 	// Overrides existing value if specified.
 	Group string
@@ -243,54 +668,270 @@ type OverrideConfig struct {
 	URLTemplate string
 	// ForceVersion to the specified version.
 	ForceVersion string
+	// AllowDowngrade permits the agent to downgrade to a version lower than
+	// the active version. Implied by ForceVersion.
+	AllowDowngrade bool
+	// Enterprise forces installation of the Enterprise edition of Teleport.
+	// Required for Enterprise installs when the proxy is not consulted (e.g., with ForceVersion).
+	Enterprise bool
+	// FIPS forces installation of the FIPS flavor of Teleport.
+	// Required for FIPS installs when the proxy is not consulted (e.g., with ForceVersion).
+	FIPS bool
+}
+
+// ProxyPersistMode specifies whether a proxy passed as an override is
+// persisted to update.yaml or used only for the current run.
+type ProxyPersistMode int
+
+const (
+	// ProxyPersistAlways persists an overridden proxy to update.yaml, so that
+	// scheduled updates use it from then on.
+	ProxyPersistAlways ProxyPersistMode = iota
+	// ProxyPersistNever uses an overridden proxy only for the current run,
+	// leaving the proxy recorded in update.yaml unchanged.
+	ProxyPersistNever
+)
+
+// installFlags returns the InstallFlags specified by the override.
+func (c OverrideConfig) installFlags() InstallFlags {
+	var flags InstallFlags
+	if c.Enterprise {
+		flags |= FlagEnterprise
+	}
+	if c.FIPS {
+		flags |= FlagFIPS
+	}
+	return flags
 }
 
 // Enable enables agent updates and attempts an initial update.
 // If the initial update succeeds, auto-updates are enabled and the configuration is persisted.
-// Otherwise, the auto-updates configuration is not changed.
+// An update that is refused as a downgrade is tolerated as a no-op: auto-updates
+// are still enabled and ErrNotNeeded is returned, so that the agent follows the
+// cluster once it advertises a newer version again.
+// On any other failure, the auto-updates configuration is not changed.
 // This function is idempotent.
 func (u *Updater) Enable(ctx context.Context, override OverrideConfig) error {
 	// Read configuration from update.yaml and override any new values passed as flags.
-	cfg, err := readConfig(u.ConfigPath)
+	cfg, err := u.readConfig(ctx, u.ConfigPath)
 	if err != nil {
 		return trace.Errorf("failed to read %s: %w", updateConfigName, err)
 	}
+	origProxy := cfg.Spec.Proxy
+	// Fall back to the proxy address in the agent's Teleport configuration
+	// file, so that existing installations do not need to pass --proxy.
+	if cfg.Spec.Proxy == "" && override.Proxy == "" && u.TeleportConfigPath != "" {
+		switch proxy, err := readTeleportProxy(u.TeleportConfigPath); {
+		case err != nil:
+			u.Log.WarnContext(ctx, "Failed to read proxy address from Teleport configuration.", "path", u.TeleportConfigPath, "error", err)
+		case proxy != "":
+			u.Log.InfoContext(ctx, "Using proxy address from Teleport configuration.", "proxy", proxy, "path", u.TeleportConfigPath)
+			cfg.Spec.Proxy = proxy
+			origProxy = proxy
+		}
+	}
 	if err := validateConfigSpec(&cfg.Spec, override); err != nil {
 		return trace.Wrap(err)
 	}
+	// Remove stale download artifacts from interrupted runs before downloading.
+	if err := u.Installer.Cleanup(ctx); err != nil {
+		u.Log.WarnContext(ctx, "Failed to clean up stale download artifacts.", "error", err)
+	}
+	updateErr := u.update(ctx, cfg, override, false)
+	// Retry transient failures, if configured. Versions installed by a failed
+	// attempt remain on disk, so retries skip the download.
+	for attempt := 1; updateErr != nil && attempt <= u.EnableRetries; attempt++ {
+		if errors.Is(updateErr, ErrNotNeeded) ||
+			errors.Is(updateErr, context.Canceled) {
+			break
+		}
+		u.Log.WarnContext(ctx, "Enable attempt failed. Retrying.", "attempt", attempt, "retries", u.EnableRetries, "error", updateErr)
+		updateErr = u.update(ctx, cfg, override, false)
+	}
+	if errors.Is(updateErr, ErrNotNeeded) {
+		// A refused downgrade is a no-op, not a failure: auto-updates are
+		// still enabled below, so the update is not reported as an outcome.
+		u.Log.InfoContext(ctx, "Enable completed without an update.")
+	} else {
+		u.reportUpdate(ctx, cfg, updateErr)
+		if updateErr != nil {
+			return trace.Wrap(updateErr)
+		}
+	}
 
-	// Lookup target version from the proxy.
+	// Always write the configuration file if enable succeeds or no update is needed.
 
-	addr, err := libutils.ParseAddr(cfg.Spec.Proxy)
+	cfg.Spec.Enabled = true
+	cfg.Status.Failures = 0
+	cfg.Status.LastFailure = time.Time{}
+	if override.Proxy != "" && override.ProxyPersist == ProxyPersistNever {
+		// The overridden proxy applies to this run only; restore the proxy
+		// recorded in update.yaml for scheduled updates.
+		u.Log.InfoContext(ctx, "Overridden proxy used for this run only.", "proxy", override.Proxy, "persisted_proxy", origProxy)
+		cfg.Spec.Proxy = origProxy
+	}
+	if err := writeConfig(u.ConfigPath, cfg); err != nil {
+		return trace.Errorf("failed to write %s: %w", updateConfigName, err)
+	}
+	u.Log.InfoContext(ctx, "Configuration updated.")
+	return trace.Wrap(updateErr)
+}
+
+// Update initiates an agent update if auto-updates are enabled in update.yaml.
+// Unlike Enable, Update does not modify the update.yaml spec, and it returns
+// ErrNotNeeded if auto-updates are disabled.
+// This allows scheduled runs to respect the user's choice to disable updates.
+// This function is idempotent.
+func (u *Updater) Update(ctx context.Context) error {
+	// Read configuration from update.yaml.
+	cfg, err := u.readConfig(ctx, u.ConfigPath)
 	if err != nil {
-		return trace.Errorf("failed to parse proxy server address: %w", err)
+		return trace.Errorf("failed to read %s: %w", updateConfigName, err)
+	}
+	if !cfg.Spec.Enabled {
+		u.Log.InfoContext(ctx, "Automatic updates disabled. Not updating.")
+		return trace.Wrap(ErrNotNeeded)
+	}
+	if err := validateConfigSpec(&cfg.Spec, OverrideConfig{}); err != nil {
+		return trace.Wrap(err)
 	}
+	// Remove stale download artifacts from interrupted runs before downloading.
+	if err := u.Installer.Cleanup(ctx); err != nil {
+		u.Log.WarnContext(ctx, "Failed to clean up stale download artifacts.", "error", err)
+	}
+	updateErr := u.update(ctx, cfg, OverrideConfig{}, true)
+	if errors.Is(updateErr, ErrNotNeeded) {
+		return trace.Wrap(updateErr)
+	}
+	u.reportUpdate(ctx, cfg, updateErr)
+
+	// Track consecutive failures, so that the failure cooldown survives
+	// process restarts. The counter is reset on the first success.
+
+	if updateErr != nil {
+		cfg.Status.Failures++
+		cfg.Status.LastFailure = time.Now().UTC()
+	} else {
+		cfg.Status.Failures = 0
+		cfg.Status.LastFailure = time.Time{}
+	}
+
+	// Write the configuration file to record any status change, even on
+	// failure, so that the failure count is persisted.
+
+	if err := writeConfig(u.ConfigPath, cfg); err != nil {
+		if updateErr != nil {
+			u.Log.WarnContext(ctx, "Failed to persist failure count after failed update.", "error", err)
+			return trace.Wrap(updateErr)
+		}
+		return trace.Errorf("failed to write %s: %w", updateConfigName, err)
+	}
+	return trace.Wrap(updateErr)
+}
+
+// update installs, links, and restarts the version desired by the cluster (or
+// by override), updating cfg.Status to reflect the result.
+// If scheduled is set, updates that would reload Teleport more frequently than
+// MinReloadInterval are deferred with ErrNotNeeded.
+// The caller is responsible for persisting cfg.
+func (u *Updater) update(ctx context.Context, cfg *UpdateConfig, override OverrideConfig, scheduled bool) error {
+	// Reconcile status with an externally repointed active link before acting
+	// on it, so that version comparisons below reflect the running version.
+
+	if err := u.checkLinkDrift(ctx, cfg); err != nil {
+		return trace.Wrap(err)
+	}
+
+	// Enforce a growing cooldown after repeated failures, so that a bad
+	// version pushed cluster-wide does not cause every agent to hammer the
+	// proxy and CDN on each timer tick.
+
+	if scheduled && cfg.Status.Failures > 0 {
+		cooldown := failureCooldown(cfg.Status.Failures)
+		if elapsed := time.Since(cfg.Status.LastFailure); elapsed < cooldown {
+			u.Log.WarnContext(ctx, "Deferring update due to cooldown after repeated failures.",
+				"failures", cfg.Status.Failures, "cooldown", cooldown, "elapsed", elapsed.Round(time.Second))
+			return trace.Wrap(ErrNotNeeded)
+		}
+	}
+
+	// Lookup target version from the proxy.
+
 	desiredVersion := override.ForceVersion
-	var flags InstallFlags
+	flags := override.installFlags()
+	agentUpdate := true
 	if desiredVersion == "" {
-		resp, err := webclient.Find(&webclient.Config{
-			Context:     ctx,
-			ProxyAddr:   addr.Addr,
-			Insecure:    u.InsecureSkipVerify,
-			Timeout:     30 * time.Second,
-			UpdateGroup: cfg.Spec.Group,
-			Pool:        u.Pool,
-		})
+		var clusterFlags InstallFlags
+		var err error
+		desiredVersion, clusterFlags, agentUpdate, err = u.find(ctx, cfg)
 		if err != nil {
-			return trace.Errorf("failed to request version from proxy: %w", err)
+			return trace.Wrap(err)
 		}
-		desiredVersion = resp.AutoUpdate.AgentVersion
-		if resp.Edition == "ent" {
-			flags |= FlagEnterprise
+		// Flags forced by the operator must not contradict the cluster.
+		if flags != 0 && flags != clusterFlags {
+			return trace.Errorf("forced install flags (%d) contradict the flags expected by the cluster (%d)", int(flags), int(clusterFlags))
 		}
-		if resp.FIPS {
-			flags |= FlagFIPS
+		flags = clusterFlags
+	} else if flags == 0 {
+		// ForceVersion skips the proxy lookup, so the edition/FIPS flags expected
+		// by the cluster cannot be derived and must be forced by the operator.
+		// If no flags were forced, warn if the cluster is reachable and expects
+		// different flags. The lookup is advisory only and bounded by a short
+		// timeout, as forced versions are commonly used on air-gapped hosts
+		// where the proxy is unreachable. Explicitly forced flags skip the
+		// lookup entirely.
+		findCtx, cancel := context.WithTimeout(ctx, advisoryFindTimeout)
+		defer cancel()
+		if _, clusterFlags, _, err := u.find(findCtx, cfg); err != nil {
+			u.Log.DebugContext(ctx, "Unable to verify install flags for forced version against cluster.", "error", err)
+		} else if clusterFlags != flags {
+			u.Log.WarnContext(ctx, "Install flags for forced version differ from the flags expected by the cluster.", "flags", int(flags), "cluster_flags", int(clusterFlags))
 		}
 	}
 
 	if desiredVersion == "" {
 		return trace.Errorf("agent version not available from Teleport cluster")
 	}
+	if len(cfg.Spec.AllowedVersions) > 0 &&
+		!slices.Contains(cfg.Spec.AllowedVersions, desiredVersion) {
+		return trace.Errorf("version %q is not in the allowed_versions list in %s", desiredVersion, updateConfigName)
+	}
+
+	// Refuse to downgrade unless explicitly allowed.
+	// A proxy may briefly advertise an older version (e.g., during a cluster
+	// rollback); the agent must not silently follow it.
+
+	if active := cfg.Status.ActiveVersion; active != "" && desiredVersion != active &&
+		override.ForceVersion == "" && !override.AllowDowngrade {
+		switch downgrade, err := isDowngrade(desiredVersion, active); {
+		case err != nil:
+			u.Log.DebugContext(ctx, "Unable to compare versions for downgrade detection.", "error", err)
+		case downgrade:
+			u.Log.WarnContext(ctx, "Refusing to downgrade Teleport without --allow-downgrade.",
+				"active_version", active, "desired_version", desiredVersion)
+			return trace.Wrap(ErrNotNeeded)
+		}
+	}
+
+	// Respect the cluster's group rollout schedule.
+	// Scheduled updates wait until the cluster schedules the agent's group.
+
+	if scheduled && !agentUpdate && cfg.Status.ActiveVersion != desiredVersion {
+		u.Log.InfoContext(ctx, "Update available, but not yet scheduled by the cluster for this update group. Waiting for rollout.",
+			"group", cfg.Spec.Group, "version", desiredVersion)
+		return trace.Wrap(ErrNotNeeded)
+	}
+
+	// Defer scheduled updates that would reload Teleport too frequently.
+
+	if scheduled && u.MinReloadInterval > 0 &&
+		cfg.Status.ActiveVersion != desiredVersion && !cfg.Status.LastReload.IsZero() {
+		if elapsed := time.Since(cfg.Status.LastReload); elapsed < u.MinReloadInterval {
+			u.Log.WarnContext(ctx, "Deferring update to avoid reloading Teleport too frequently.",
+				"elapsed", elapsed.Round(time.Second), "min_interval", u.MinReloadInterval)
+			return trace.Wrap(ErrNotNeeded)
+		}
+	}
 	switch cfg.Status.BackupVersion {
 	case "", desiredVersion, cfg.Status.ActiveVersion:
 	default:
@@ -306,18 +947,37 @@ func (u *Updater) Enable(ctx context.Context, override OverrideConfig) error {
 	}
 
 	// Install the desired version (or validate existing installation)
+	// Templates are tried in order until one produces a verified download.
 
-	template := cfg.Spec.URLTemplate
-	if template == "" {
-		template = cdnURITemplate
+	if err := u.runHook(ctx, "pre-install", u.PreInstall, desiredVersion); err != nil {
+		return trace.Wrap(err)
 	}
-	err = u.Installer.Install(ctx, desiredVersion, template, flags)
-	if err != nil {
-		return trace.Errorf("failed to install: %w", err)
+	templates := cfg.Spec.urlTemplates()
+	var installErr error
+	var installedTemplate string
+	for _, template := range templates {
+		installErr = u.Installer.Install(ctx, desiredVersion, template, flags)
+		if installErr == nil {
+			installedTemplate = template
+			if len(templates) > 1 {
+				u.Log.InfoContext(ctx, "Teleport installed from download mirror.", "template", template)
+			}
+			break
+		}
+		if errors.Is(installErr, context.Canceled) {
+			break
+		}
+		u.Log.WarnContext(ctx, "Failed to install Teleport from download mirror.", "template", template, "error", installErr)
+	}
+	if installErr != nil {
+		return trace.Wrap(&InstallError{Version: desiredVersion, Err: installErr})
+	}
+	if err := u.runHook(ctx, "post-install", u.PostInstall, desiredVersion); err != nil {
+		return trace.Wrap(err)
 	}
 	revert, err := u.Installer.Link(ctx, desiredVersion)
 	if err != nil {
-		return trace.Errorf("failed to link: %w", err)
+		return trace.Wrap(&LinkError{Version: desiredVersion, Err: err})
 	}
 
 	// If we fail to revert after this point, the next update/enable will
@@ -325,7 +985,8 @@ func (u *Updater) Enable(ctx context.Context, override OverrideConfig) error {
 
 	// Sync process configuration after linking.
 
-	if err := u.Process.Sync(ctx); err != nil {
+	procs := append([]Process{u.Process}, u.ExtraProcesses...)
+	if err := syncProcesses(ctx, procs); err != nil {
 		if errors.Is(err, context.Canceled) {
 			return trace.Errorf("sync canceled")
 		}
@@ -333,37 +994,84 @@ func (u *Updater) Enable(ctx context.Context, override OverrideConfig) error {
 		u.Log.ErrorContext(ctx, "Reverting symlinks due to invalid configuration.")
 		if ok := revert(ctx); !ok {
 			u.Log.ErrorContext(ctx, "Failed to revert Teleport symlinks. Installation likely broken.")
-		} else if err := u.Process.Sync(ctx); err != nil {
+		} else if err := syncProcesses(ctx, procs); err != nil {
 			u.Log.ErrorContext(ctx, "Failed to sync configuration after failed restart.", "error", err)
 		}
 		u.Log.WarnContext(ctx, "Teleport updater encountered a configuration error and successfully reverted the installation.")
 
-		return trace.Errorf("failed to validate configuration for new version %q of Teleport: %w", desiredVersion, err)
+		return trace.Wrap(&SyncError{Version: desiredVersion, Err: err})
 	}
 
 	// Restart Teleport if necessary.
+	// Services are reloaded one at a time, with health verification between
+	// each, so that a failure halts the rollout before affecting all services.
 
-	if cfg.Status.ActiveVersion != desiredVersion {
+	// A flag change at the same version (e.g., a FIPS toggle) swaps the
+	// binaries without a version bump, so it must also reload Teleport.
+	flagsChanged := cfg.Status.ActiveVersion == desiredVersion &&
+		cfg.Status.ActiveFlags != flags
+
+	if cfg.Status.ActiveVersion != desiredVersion || flagsChanged {
 		u.Log.InfoContext(ctx, "Target version successfully installed.", "version", desiredVersion)
-		if err := u.Process.Reload(ctx); err != nil && !errors.Is(err, ErrNotNeeded) {
-			if errors.Is(err, context.Canceled) {
+		var reloadErr error
+		var reloaded []Process
+		for _, p := range procs {
+			err := p.Reload(ctx)
+			if err == nil && u.HealthCheck != nil {
+				if herr := u.HealthCheck(ctx); herr != nil {
+					err = trace.Errorf("health check failed: %w", herr)
+				}
+			}
+			if err != nil && !errors.Is(err, ErrNotNeeded) {
+				reloadErr = err
+				break
+			}
+			reloaded = append(reloaded, p)
+		}
+		if reloadErr != nil {
+			if errors.Is(reloadErr, context.Canceled) {
 				return trace.Errorf("reload canceled")
 			}
-			// If reloading Teleport at the new version fails, revert, resync, and reload.
+			// If reloading Teleport at the new version fails, revert, resync, and
+			// reload the failed service and any services already restarted at the
+			// new version. Services that were not yet reloaded remain on the old
+			// version and are left untouched.
 			u.Log.ErrorContext(ctx, "Reverting symlinks due to failed restart.")
 			if ok := revert(ctx); !ok {
 				u.Log.ErrorContext(ctx, "Failed to revert Teleport symlinks to older version. Installation likely broken.")
-			} else if err := u.Process.Sync(ctx); err != nil {
+			} else if err := syncProcesses(ctx, procs); err != nil {
 				u.Log.ErrorContext(ctx, "Invalid configuration found after reverting Teleport to older version. Installation likely broken.", "error", err)
-			} else if err := u.Process.Reload(ctx); err != nil && !errors.Is(err, ErrNotNeeded) {
-				u.Log.ErrorContext(ctx, "Failed to revert Teleport to older version. Installation likely broken.", "error", err)
+			} else {
+				for _, p := range procs[:len(reloaded)+1] {
+					if err := p.Reload(ctx); err != nil && !errors.Is(err, ErrNotNeeded) {
+						u.Log.ErrorContext(ctx, "Failed to revert Teleport to older version. Installation likely broken.", "error", err)
+					}
+				}
 			}
 			u.Log.WarnContext(ctx, "Teleport updater encountered a configuration error and successfully reverted the installation.")
 
-			return trace.Errorf("failed to start new version %q of Teleport: %w", desiredVersion, err)
+			return trace.Wrap(&ReloadError{Version: desiredVersion, Err: reloadErr})
+		}
+		if err := u.runHook(ctx, "post-reload", u.PostReload, desiredVersion); err != nil {
+			return trace.Wrap(err)
+		}
+		if flagsChanged {
+			u.Log.InfoContext(ctx, "Install flags changed at the active version. Teleport reinstalled without a version change.",
+				"version", desiredVersion, "old_flags", cfg.Status.ActiveFlags.Strings(), "new_flags", flags.Strings())
+		} else {
+			cfg.Status.BackupVersion = cfg.Status.ActiveVersion
+			cfg.Status.ActiveVersion = desiredVersion
+		}
+		cfg.Status.ActiveFlags = flags
+		// Record the resolved download URL of the new active version for provenance.
+		source, err := makeURL(installedTemplate, desiredVersion, flags)
+		if err != nil {
+			u.Log.WarnContext(ctx, "Failed to resolve download source URL for status.", "error", err)
+		}
+		cfg.Status.ActiveSource = source
+		if u.MinReloadInterval > 0 {
+			cfg.Status.LastReload = time.Now().UTC()
 		}
-		cfg.Status.BackupVersion = cfg.Status.ActiveVersion
-		cfg.Status.ActiveVersion = desiredVersion
 	} else {
 		u.Log.InfoContext(ctx, "Target version successfully validated.", "version", desiredVersion)
 	}
@@ -371,30 +1079,427 @@ func (u *Updater) Enable(ctx context.Context, override OverrideConfig) error {
 		u.Log.InfoContext(ctx, "Backup version set.", "version", v)
 	}
 
-	// Check if manual cleanup might be needed.
+	// Remove versions that are neither active nor backup.
+	// The linked version is never removed, even if untracked in status.
 
 	versions, err := u.Installer.List(ctx)
 	if err != nil {
 		return trace.Errorf("failed to list installed versions: %w", err)
 	}
-	if n := len(versions); n > 2 {
-		u.Log.WarnContext(ctx, "More than 2 versions of Teleport installed. Version directory may need cleanup to save space.", "count", n)
+	keep := u.KeepVersions
+	if keep <= 0 {
+		keep = defaultKeepVersions
+	}
+	remaining := len(versions)
+	for _, v := range versions {
+		if remaining <= keep {
+			break
+		}
+		if v == cfg.Status.ActiveVersion ||
+			v == cfg.Status.BackupVersion {
+			continue
+		}
+		switch err := u.Installer.Remove(ctx, v); {
+		case errors.Is(err, ErrLinked):
+			u.Log.WarnContext(ctx, "Refusing to remove linked version of Teleport.", "version", v)
+			continue
+		case err != nil:
+			u.Log.WarnContext(ctx, "Failed to remove unused version of Teleport.", "version", v, "error", err)
+			continue
+		}
+		u.Log.InfoContext(ctx, "Removed unused version of Teleport.", "version", v)
+		remaining--
+	}
+	if remaining > keep {
+		if u.StrictVersionLimit {
+			return trace.Errorf("%d versions of Teleport remain after cleanup, exceeding the configured limit of %d", remaining, keep)
+		}
+		u.Log.WarnContext(ctx, "More versions of Teleport installed than configured to keep. Version directory may need cleanup to save space.", "count", remaining, "keep", keep)
 	}
+	return nil
+}
 
-	// Always write the configuration file if enable succeeds.
+// LinkDriftPolicy specifies how an active link that was externally repointed
+// to a version other than the one recorded in update.yaml status is handled.
+type LinkDriftPolicy int
 
-	cfg.Spec.Enabled = true
-	if err := writeConfig(u.ConfigPath, cfg); err != nil {
-		return trace.Errorf("failed to write %s: %w", updateConfigName, err)
+const (
+	// LinkDriftWarn logs a warning without correcting the discrepancy.
+	LinkDriftWarn LinkDriftPolicy = iota
+	// LinkDriftAdopt updates the recorded active version to match the link.
+	LinkDriftAdopt
+	// LinkDriftRepair restores the link to the recorded active version.
+	LinkDriftRepair
+)
+
+// checkLinkDrift detects an active link that was externally repointed to an
+// installed version other than the one recorded in status, and reconciles the
+// two according to the configured policy.
+func (u *Updater) checkLinkDrift(ctx context.Context, cfg *UpdateConfig) error {
+	active := cfg.Status.ActiveVersion
+	if active == "" {
+		return nil
+	}
+	linked, err := u.Installer.LinkedVersion(ctx)
+	if err != nil {
+		return trace.Errorf("failed to determine linked version: %w", err)
+	}
+	if linked == "" || linked == active {
+		return nil
+	}
+	switch u.LinkDriftPolicy {
+	case LinkDriftAdopt:
+		u.Log.WarnContext(ctx, "Active link was changed outside of the updater. Adopting the linked version as active.",
+			"status_version", active, "linked_version", linked)
+		cfg.Status.ActiveVersion = linked
+	case LinkDriftRepair:
+		u.Log.WarnContext(ctx, "Active link was changed outside of the updater. Restoring the link to the active version.",
+			"status_version", active, "linked_version", linked)
+		if _, err := u.Installer.Link(ctx, active); err != nil {
+			return trace.Errorf("failed to restore link to active version %q: %w", active, err)
+		}
+	default:
+		u.Log.WarnContext(ctx, "Active link was changed outside of the updater. The linked version of Teleport is running.",
+			"status_version", active, "linked_version", linked)
 	}
-	u.Log.InfoContext(ctx, "Configuration updated.")
 	return nil
 }
 
+// readTeleportProxy returns the proxy address from the Teleport configuration
+// file at path. Only the proxy address is parsed; all other fields are ignored.
+// Returns an empty string if the file does not specify a proxy address.
+func readTeleportProxy(path string) (string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", trace.Wrap(err)
+	}
+	var cfg struct {
+		Teleport struct {
+			ProxyServer string `yaml:"proxy_server"`
+		} `yaml:"teleport"`
+	}
+	if err := yaml.Unmarshal(b, &cfg); err != nil {
+		return "", trace.Wrap(err)
+	}
+	return cfg.Teleport.ProxyServer, nil
+}
+
+// runHook executes an operator-configured hook command, streaming its output
+// to the logger. The version and the path of its installation directory are
+// provided to the command via the TELEPORT_UPDATE_VERSION and
+// TELEPORT_UPDATE_VERSION_PATH environment variables.
+func (u *Updater) runHook(ctx context.Context, name string, argv []string, version string) error {
+	if len(argv) == 0 {
+		return nil
+	}
+	u.Log.InfoContext(ctx, "Running hook.", "hook", name, "command", argv)
+	cmd := exec.CommandContext(ctx, argv[0], argv[1:]...)
+	cmd.Env = append(os.Environ(),
+		"TELEPORT_UPDATE_VERSION="+version,
+		"TELEPORT_UPDATE_VERSION_PATH="+filepath.Join(u.InstallDir, version),
+	)
+	stdout := &lineLogger{ctx: ctx, log: u.Log, level: slog.LevelInfo}
+	stderr := &lineLogger{ctx: ctx, log: u.Log, level: slog.LevelWarn}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	err := cmd.Run()
+	stdout.Flush()
+	stderr.Flush()
+	if err != nil {
+		return trace.Errorf("%s hook failed: %w", name, err)
+	}
+	return nil
+}
+
+// failureCooldown returns the wait enforced after the given number of
+// consecutive update failures, doubling per failure up to failureCooldownMax.
+func failureCooldown(failures int) time.Duration {
+	cooldown := failureCooldownBase
+	for i := 1; i < failures && cooldown < failureCooldownMax; i++ {
+		cooldown *= 2
+	}
+	return min(cooldown, failureCooldownMax)
+}
+
+// isDowngrade returns true if the desired version is semantically lower than
+// the active version. Pre-release versions (e.g., 16.3.0-rc.1) compare lower
+// than their corresponding release.
+func isDowngrade(desired, active string) (bool, error) {
+	d, err := semver.NewVersion(strings.TrimPrefix(desired, "v"))
+	if err != nil {
+		return false, trace.Errorf("failed to parse desired version %q: %w", desired, err)
+	}
+	a, err := semver.NewVersion(strings.TrimPrefix(active, "v"))
+	if err != nil {
+		return false, trace.Errorf("failed to parse active version %q: %w", active, err)
+	}
+	return d.LessThan(*a), nil
+}
+
+// syncProcesses validates and synchronizes process configuration for each process, in order.
+func syncProcesses(ctx context.Context, procs []Process) error {
+	for _, p := range procs {
+		if err := p.Sync(ctx); err != nil {
+			return trace.Wrap(err)
+		}
+	}
+	return nil
+}
+
+// stopProcesses stops each running process, in order.
+// Processes that are already stopped are skipped.
+func stopProcesses(ctx context.Context, procs []Process) error {
+	for _, p := range procs {
+		if err := p.Stop(ctx); err != nil && !errors.Is(err, ErrNotNeeded) {
+			return trace.Wrap(err)
+		}
+	}
+	return nil
+}
+
+// find queries the proxy to determine the version and install flags desired by
+// the cluster, and whether the agent's update group is currently scheduled to update.
+func (u *Updater) find(ctx context.Context, cfg *UpdateConfig) (string, InstallFlags, bool, error) {
+	addr, err := libutils.ParseAddr(cfg.Spec.Proxy)
+	if err != nil {
+		return "", 0, false, trace.Errorf("failed to parse proxy server address: %w", err)
+	}
+	resp, err := webclient.Find(&webclient.Config{
+		Context:     ctx,
+		ProxyAddr:   addr.Addr,
+		Insecure:    u.InsecureSkipVerify,
+		Timeout:     30 * time.Second,
+		UpdateGroup: cfg.Spec.Group,
+		Pool:        u.Pool,
+	})
+	if err != nil {
+		return "", 0, false, trace.Errorf("failed to request version from proxy: %w", err)
+	}
+	var flags InstallFlags
+	if resp.Edition == "ent" {
+		flags |= FlagEnterprise
+	}
+	if resp.FIPS {
+		flags |= FlagFIPS
+	}
+	return resp.AutoUpdate.AgentVersion, flags, resp.AutoUpdate.AgentAutoUpdate, nil
+}
+
+// SelfCheck validates the updater's on-disk state at startup, including the
+// versions directory, update.yaml consistency, and the presence and integrity
+// of installed versions referenced by status.
+// All detected issues are returned together as a single aggregate error.
+func (u *Updater) SelfCheck(ctx context.Context) error {
+	var issues []error
+	cfg, err := u.readConfig(ctx, u.ConfigPath)
+	if err != nil {
+		issues = append(issues, trace.Errorf("failed to read %s: %w", updateConfigName, err))
+	}
+	versions, listErr := u.Installer.List(ctx)
+	if listErr != nil {
+		issues = append(issues, trace.Errorf("failed to list installed versions: %w", listErr))
+	}
+	if err == nil && listErr == nil {
+		if v := cfg.Status.ActiveVersion; v != "" && !slices.Contains(versions, v) {
+			issues = append(issues, trace.Errorf("active version %q is not installed", v))
+		}
+		if v := cfg.Status.BackupVersion; v != "" && !slices.Contains(versions, v) {
+			issues = append(issues, trace.Errorf("backup version %q is not installed", v))
+		}
+		if cfg.Spec.Enabled && cfg.Status.ActiveVersion == "" {
+			issues = append(issues, trace.Errorf("automatic updates are enabled, but no active version is recorded"))
+		}
+		// Verify the integrity of installed versions referenced by status.
+		for _, v := range []string{cfg.Status.ActiveVersion, cfg.Status.BackupVersion} {
+			if v == "" || !slices.Contains(versions, v) {
+				continue
+			}
+			if err := u.Installer.Verify(ctx, v); err != nil {
+				issues = append(issues, trace.Wrap(err))
+			}
+		}
+	}
+	return trace.NewAggregate(issues...)
+}
+
+// PreflightCheck is the result of a single preflight check.
+type PreflightCheck struct {
+	// Name identifies the check.
+	Name string `json:"name"`
+	// Passed indicates whether the check passed.
+	Passed bool `json:"passed"`
+	// Error describes why the check failed.
+	Error string `json:"error,omitempty"`
+	// Hint suggests how to remediate a failed check.
+	Hint string `json:"hint,omitempty"`
+}
+
+// PreflightReport contains the result of each preflight check.
+type PreflightReport struct {
+	// Checks in the order they were performed.
+	Checks []PreflightCheck `json:"checks"`
+}
+
+// Passed returns true if every preflight check passed.
+func (r PreflightReport) Passed() bool {
+	for _, c := range r.Checks {
+		if !c.Passed {
+			return false
+		}
+	}
+	return true
+}
+
+// Preflight validates that the host is ready for agent auto-updates.
+// It verifies that the installation directories are writable with sufficient
+// free disk, that the configured proxy is reachable and advertises an agent
+// version, and that the system process manager is available.
+// Failed checks are recorded in the report with remediation hints; an error is
+// only returned if the report could not be produced.
+func (u *Updater) Preflight(ctx context.Context) (PreflightReport, error) {
+	var report PreflightReport
+	record := func(name string, err error, hint string) {
+		check := PreflightCheck{Name: name, Passed: err == nil}
+		if err != nil {
+			check.Error = err.Error()
+			check.Hint = hint
+		}
+		report.Checks = append(report.Checks, check)
+	}
+
+	cfg, cfgErr := u.readConfig(ctx, u.ConfigPath)
+	record("configuration", cfgErr,
+		"ensure "+updateConfigName+" is valid, owned by root, and not world-writable")
+
+	record("disk", u.Installer.CheckDisk(ctx),
+		"ensure the versions and link directories are writable and have free disk space")
+
+	proxyErr := cfgErr
+	switch {
+	case cfgErr != nil:
+	case cfg.Spec.Proxy == "":
+		proxyErr = trace.Errorf("no proxy address configured")
+	default:
+		var version string
+		if version, _, _, proxyErr = u.find(ctx, cfg); proxyErr == nil && version == "" {
+			proxyErr = trace.Errorf("proxy did not advertise an agent version")
+		}
+	}
+	record("proxy", proxyErr,
+		"ensure the proxy address is set (e.g., via enable --proxy) and reachable from this host")
+
+	record("process", syncProcesses(ctx, append([]Process{u.Process}, u.ExtraProcesses...)),
+		"ensure systemd is available and the Teleport services are installed")
+
+	return report, trace.Wrap(ctx.Err())
+}
+
+// Inventory describes the local Teleport installation state, in a
+// machine-friendly format suitable for aggregation by fleet reporting systems.
+type Inventory struct {
+	// Hostname of the machine.
+	Hostname string `json:"hostname"`
+	// ActiveVersion is the currently active Teleport version.
+	ActiveVersion string `json:"active_version"`
+	// BackupVersion is the last working version of Teleport.
+	BackupVersion string `json:"backup_version,omitempty"`
+	// ActiveFlags are the install flags of the active version.
+	ActiveFlags InstallFlags `json:"active_flags,omitempty"`
+	// Versions lists all installed versions of Teleport.
+	Versions []InstallInfo `json:"versions"`
+}
+
+// Inventory returns the installed version inventory of the local machine.
+func (u *Updater) Inventory(ctx context.Context) (Inventory, error) {
+	var inv Inventory
+	hostname, err := os.Hostname()
+	if err != nil {
+		return inv, trace.Errorf("failed to determine hostname: %w", err)
+	}
+	inv.Hostname = hostname
+	cfg, err := u.readConfig(ctx, u.ConfigPath)
+	if err != nil {
+		return inv, trace.Errorf("failed to read %s: %w", updateConfigName, err)
+	}
+	inv.ActiveVersion = cfg.Status.ActiveVersion
+	inv.BackupVersion = cfg.Status.BackupVersion
+	inv.ActiveFlags = cfg.Status.ActiveFlags
+	versions, err := u.Installer.List(ctx)
+	if err != nil {
+		return inv, trace.Errorf("failed to list installed versions: %w", err)
+	}
+	for _, v := range versions {
+		info, err := u.Installer.Stat(ctx, v)
+		if err != nil {
+			return inv, trace.Wrap(err)
+		}
+		inv.Versions = append(inv.Versions, info)
+	}
+	return inv, nil
+}
+
+// updateReportPath is the proxy web API path that receives update outcome reports.
+const updateReportPath = "/v1/webapi/autoupdate/report"
+
+// updateReport describes an update outcome reported to the proxy.
+type updateReport struct {
+	// Group identifier for the agent installation.
+	Group string `json:"group,omitempty"`
+	// ActiveVersion after the update attempt.
+	ActiveVersion string `json:"active_version"`
+	// Success indicates whether the update attempt succeeded.
+	Success bool `json:"success"`
+	// Error contains the update error, if any.
+	Error string `json:"error,omitempty"`
+}
+
+// reportUpdate reports the outcome of an update attempt back to the proxy.
+// Reporting is best-effort and never fails the update: errors are logged.
+func (u *Updater) reportUpdate(ctx context.Context, cfg *UpdateConfig, updateErr error) {
+	if !u.ReportUpdates || cfg.Spec.Proxy == "" {
+		return
+	}
+	addr, err := libutils.ParseAddr(cfg.Spec.Proxy)
+	if err != nil {
+		u.Log.WarnContext(ctx, "Failed to parse proxy server address for update report.", "error", err)
+		return
+	}
+	report := updateReport{
+		Group:         cfg.Spec.Group,
+		ActiveVersion: cfg.Status.ActiveVersion,
+		Success:       updateErr == nil,
+	}
+	if updateErr != nil {
+		report.Error = updateErr.Error()
+	}
+	body, err := json.Marshal(report)
+	if err != nil {
+		u.Log.WarnContext(ctx, "Failed to encode update report.", "error", err)
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "https://"+addr.Addr+updateReportPath, bytes.NewReader(body))
+	if err != nil {
+		u.Log.WarnContext(ctx, "Failed to create update report request.", "error", err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := u.HTTP.Do(req)
+	if err != nil {
+		u.Log.WarnContext(ctx, "Failed to report update outcome to proxy.", "error", err)
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		u.Log.WarnContext(ctx, "Proxy rejected update outcome report.", "code", resp.StatusCode)
+	}
+}
+
 // Disable disables agent auto-updates.
 // This function is idempotent.
 func (u *Updater) Disable(ctx context.Context) error {
-	cfg, err := readConfig(u.ConfigPath)
+	cfg, err := u.readConfig(ctx, u.ConfigPath)
 	if err != nil {
 		return trace.Errorf("failed to read %s: %w", updateConfigName, err)
 	}
@@ -409,6 +1514,104 @@ func (u *Updater) Disable(ctx context.Context) error {
 	return nil
 }
 
+// Uninstall stops any running Teleport services and removes the system links
+// for the linked version of Teleport, leaving installed versions and
+// update.yaml on disk.
+// Returns ErrNotNeeded if no version is linked.
+// This function is idempotent.
+func (u *Updater) Uninstall(ctx context.Context) error {
+	cfg, err := u.readConfig(ctx, u.ConfigPath)
+	if err != nil {
+		return trace.Errorf("failed to read %s: %w", updateConfigName, err)
+	}
+	// Prefer the version that is actually linked over the version recorded in
+	// status, in case the link was changed outside of the updater.
+	version, err := u.Installer.LinkedVersion(ctx)
+	if err != nil {
+		return trace.Errorf("failed to determine linked version: %w", err)
+	}
+	if version == "" {
+		version = cfg.Status.ActiveVersion
+	}
+	if version == "" {
+		u.Log.InfoContext(ctx, "No version of Teleport linked. Nothing to uninstall.")
+		return trace.Wrap(ErrNotNeeded)
+	}
+	// Stop running services before unlinking, so that no service is left
+	// running a binary that is no longer linked.
+	procs := append([]Process{u.Process}, u.ExtraProcesses...)
+	if err := stopProcesses(ctx, procs); err != nil &&
+		!errors.Is(err, ErrNotSupported) {
+		return trace.Errorf("failed to stop Teleport: %w", err)
+	}
+	if err := u.Installer.Unlink(ctx, version); err != nil {
+		if errors.Is(err, ErrNotNeeded) {
+			u.Log.InfoContext(ctx, "No links present for version. Nothing to uninstall.", "version", version)
+			return trace.Wrap(err)
+		}
+		return trace.Errorf("failed to unlink version %q: %w", version, err)
+	}
+	// Sync process configuration so the process manager no longer references
+	// the removed service. A missing unit is expected after unlinking.
+	if err := syncProcesses(ctx, procs); err != nil &&
+		!errors.Is(err, ErrNotSupported) && !errors.Is(err, ErrNoUnit) {
+		u.Log.WarnContext(ctx, "Failed to sync process configuration after unlinking.", "error", err)
+	}
+	u.Log.InfoContext(ctx, "Teleport unlinked.", "version", version)
+	return nil
+}
+
+// OwnerCheckMode specifies how unsafe update.yaml ownership or permissions are handled.
+type OwnerCheckMode int
+
+const (
+	// OwnerCheckNone disables ownership and permission checks.
+	OwnerCheckNone OwnerCheckMode = iota
+	// OwnerCheckWarn logs a warning if the configuration file is world-writable
+	// or owned by an unexpected user.
+	OwnerCheckWarn
+	// OwnerCheckEnforce refuses to act if the configuration file is world-writable
+	// or owned by an unexpected user.
+	OwnerCheckEnforce
+)
+
+// readConfig reads UpdateConfig from a file, first applying the configured
+// ownership and permission checks.
+func (u *Updater) readConfig(ctx context.Context, path string) (*UpdateConfig, error) {
+	if u.ConfigOwnerCheck != OwnerCheckNone {
+		switch err := checkConfigOwnership(path); {
+		case err == nil, errors.Is(err, fs.ErrNotExist):
+		case u.ConfigOwnerCheck == OwnerCheckEnforce:
+			return nil, trace.Errorf("refusing to use %s: %w", updateConfigName, err)
+		default:
+			u.Log.WarnContext(ctx, "Unsafe ownership or permissions on update configuration.", "error", err)
+		}
+	}
+	return readConfig(path)
+}
+
+// checkConfigOwnership ensures that config tampering by unprivileged users is
+// not possible via the file at path.
+// The file must not be world-writable, and must be owned by root or the user
+// running the updater.
+func checkConfigOwnership(path string) error {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	if perm := fi.Mode().Perm(); perm&0002 != 0 {
+		return trace.Errorf("%s is world-writable (mode %04o)", updateConfigName, perm)
+	}
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return nil
+	}
+	if uid := os.Getuid(); int(st.Uid) != uid && st.Uid != 0 {
+		return trace.Errorf("%s is owned by unexpected user (uid %d)", updateConfigName, st.Uid)
+	}
+	return nil
+}
+
 // readConfig reads UpdateConfig from a file.
 func readConfig(path string) (*UpdateConfig, error) {
 	f, err := os.Open(path)
@@ -429,12 +1632,35 @@ func readConfig(path string) (*UpdateConfig, error) {
 	if k := cfg.Kind; k != updateConfigKind {
 		return nil, trace.Errorf("invalid kind %q", k)
 	}
-	if v := cfg.Version; v != updateConfigVersion {
-		return nil, trace.Errorf("invalid version %q", v)
+	// Migrate configs written by older versions of the updater to the current
+	// schema. The migrated config is persisted on the next write of update.yaml.
+	for cfg.Version != updateConfigVersion {
+		migrate, ok := updateConfigMigrations[cfg.Version]
+		if !ok {
+			return nil, trace.Errorf("invalid version %q", cfg.Version)
+		}
+		if err := migrate(&cfg); err != nil {
+			return nil, trace.Errorf("failed to migrate %s from version %q: %w", updateConfigName, cfg.Version, err)
+		}
 	}
 	return &cfg, nil
 }
 
+// updateConfigMigrations upgrade parsed update.yaml files from older schema
+// versions to the next schema version. Migrations are applied in sequence
+// until the config reaches updateConfigVersion.
+var updateConfigMigrations = map[string]func(*UpdateConfig) error{
+	updateConfigVersionV1: migrateConfigV1,
+}
+
+// migrateConfigV1 upgrades a v1 update.yaml to the v2 schema.
+// The v2 schema is a superset of v1 (active_source, active_flags, last_reload),
+// so new fields keep their zero values.
+func migrateConfigV1(cfg *UpdateConfig) error {
+	cfg.Version = updateConfigVersion
+	return nil
+}
+
 // writeConfig writes UpdateConfig to a file atomically, ensuring the file cannot be corrupted.
 func writeConfig(filename string, cfg *UpdateConfig) error {
 	opts := []renameio.Option{
@@ -453,6 +1679,71 @@ func writeConfig(filename string, cfg *UpdateConfig) error {
 	return trace.Wrap(t.CloseAtomicallyReplace())
 }
 
+// HTTPHealthCheck returns a health check hook that polls the Teleport diagnostic
+// endpoint at diagAddr (e.g., "http://127.0.0.1:3000") until it reports ready or
+// timeout elapses.
+// The Teleport diagnostic endpoint must be enabled for the check to pass.
+func HTTPHealthCheck(diagAddr string, timeout time.Duration) func(ctx context.Context) error {
+	url := strings.TrimRight(diagAddr, "/") + "/readyz"
+	return func(ctx context.Context) error {
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		for {
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+			if err != nil {
+				return trace.Wrap(err)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err == nil {
+				resp.Body.Close()
+				if resp.StatusCode == http.StatusOK {
+					return nil
+				}
+			}
+			select {
+			case <-ctx.Done():
+				return trace.Errorf("timed out waiting for Teleport to become healthy at %s", url)
+			case <-ticker.C:
+			}
+		}
+	}
+}
+
+// validatePins ensures that all provided certificate pins parse as SHA256 SPKI pins.
+func validatePins(pins []string) error {
+	for _, pin := range pins {
+		parts := strings.SplitN(pin, ":", 2)
+		if len(parts) != 2 || parts[0] != "sha256" {
+			return trace.Errorf("invalid certificate pin %q, expected format sha256:<hex>", pin)
+		}
+		if _, err := hex.DecodeString(parts[1]); err != nil {
+			return trace.Errorf("invalid certificate pin %q: pin must be hex-encoded", pin)
+		}
+	}
+	return nil
+}
+
+// verifyPinnedCerts returns a TLS verification callback that requires at least
+// one certificate in the presented chain to match one of the provided SPKI pins.
+func verifyPinnedCerts(pins []string) func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+	return func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+		for _, raw := range rawCerts {
+			cert, err := x509.ParseCertificate(raw)
+			if err != nil {
+				return trace.Errorf("failed to parse proxy certificate: %w", err)
+			}
+			for _, pin := range pins {
+				if subtle.ConstantTimeCompare([]byte(libutils.CalculateSPKI(cert)), []byte(pin)) == 1 {
+					return nil
+				}
+			}
+		}
+		return trace.Errorf("proxy certificate chain does not match any configured certificate pin")
+	}
+}
+
 func validateConfigSpec(spec *UpdateSpec, override OverrideConfig) error {
 	if override.Proxy != "" {
 		spec.Proxy = override.Proxy
@@ -463,9 +1754,11 @@ func validateConfigSpec(spec *UpdateSpec, override OverrideConfig) error {
 	if override.URLTemplate != "" {
 		spec.URLTemplate = override.URLTemplate
 	}
-	if spec.URLTemplate != "" &&
-		!strings.HasPrefix(strings.ToLower(spec.URLTemplate), "https://") {
-		return trace.Errorf("Teleport download URL must use TLS (https://)")
+	for _, template := range spec.urlTemplates() {
+		if template != cdnURITemplate &&
+			!strings.HasPrefix(strings.ToLower(template), "https://") {
+			return trace.Errorf("Teleport download URL must use TLS (https://)")
+		}
 	}
 	if spec.Proxy == "" {
 		return trace.Errorf("Teleport proxy URL must be specified with --proxy or present in %s", updateConfigName)
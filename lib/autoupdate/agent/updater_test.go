@@ -19,22 +19,29 @@
 package agent
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"encoding/pem"
 	"errors"
+	"fmt"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"regexp"
+	"slices"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"gopkg.in/yaml.v3"
 
 	"github.com/gravitational/teleport/api/client/webclient"
+	libutils "github.com/gravitational/teleport/lib/utils"
 	"github.com/gravitational/teleport/lib/utils/golden"
 )
 
@@ -123,17 +130,90 @@ func TestUpdater_Disable(t *testing.T) {
 	}
 }
 
+func TestUpdater_ConfigOwnership(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		perm os.FileMode
+		mode OwnerCheckMode
+
+		errMatch string
+	}{
+		{
+			name: "safe permissions",
+			perm: 0600,
+			mode: OwnerCheckEnforce,
+		},
+		{
+			name: "world-writable ignored by default",
+			perm: 0666,
+			mode: OwnerCheckNone,
+		},
+		{
+			name: "world-writable warns",
+			perm: 0666,
+			mode: OwnerCheckWarn,
+		},
+		{
+			name:     "world-writable refused",
+			perm:     0666,
+			mode:     OwnerCheckEnforce,
+			errMatch: "world-writable",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			cfgPath := filepath.Join(dir, "update.yaml")
+
+			b, err := yaml.Marshal(&UpdateConfig{
+				Version: updateConfigVersion,
+				Kind:    updateConfigKind,
+				Spec: UpdateSpec{
+					Enabled: true,
+				},
+			})
+			require.NoError(t, err)
+			err = os.WriteFile(cfgPath, b, tt.perm)
+			require.NoError(t, err)
+			// Force the mode in case it is masked by umask.
+			err = os.Chmod(cfgPath, tt.perm)
+			require.NoError(t, err)
+
+			updater, err := NewLocalUpdater(LocalUpdaterConfig{
+				InsecureSkipVerify: true,
+				VersionsDir:        dir,
+				ConfigOwnerCheck:   tt.mode,
+			})
+			require.NoError(t, err)
+
+			err = updater.Disable(context.Background())
+			if tt.errMatch != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.errMatch)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
 func TestUpdater_Enable(t *testing.T) {
 	t.Parallel()
 
 	tests := []struct {
-		name       string
-		cfg        *UpdateConfig // nil -> file not present
-		userCfg    OverrideConfig
-		installErr error
-		flags      InstallFlags
-		syncErr    error
-		reloadErr  error
+		name           string
+		cfg            *UpdateConfig // nil -> file not present
+		userCfg        OverrideConfig
+		installErr     error
+		flags          InstallFlags
+		syncErr        error
+		reloadErr      error
+		healthCheckErr error
+		healthCheck    bool
 
 		removedVersion    string
 		installedVersion  string
@@ -142,6 +222,7 @@ func TestUpdater_Enable(t *testing.T) {
 		syncCalls         int
 		reloadCalls       int
 		revertCalls       int
+		notNeeded         bool
 		errMatch          string
 	}{
 		{
@@ -185,6 +266,7 @@ func TestUpdater_Enable(t *testing.T) {
 
 			installedVersion:  "new-version",
 			installedTemplate: "https://example.com/new",
+			requestGroup:      "new-group",
 			syncCalls:         1,
 			reloadCalls:       1,
 		},
@@ -206,6 +288,39 @@ func TestUpdater_Enable(t *testing.T) {
 			syncCalls:         1,
 			reloadCalls:       1,
 		},
+		{
+			name: "version on allowlist",
+			cfg: &UpdateConfig{
+				Version: updateConfigVersion,
+				Kind:    updateConfigKind,
+				Spec: UpdateSpec{
+					AllowedVersions: []string{"16.2.0", "16.3.0"},
+				},
+				Status: UpdateStatus{
+					ActiveVersion: "old-version",
+				},
+			},
+
+			installedVersion:  "16.3.0",
+			installedTemplate: cdnURITemplate,
+			syncCalls:         1,
+			reloadCalls:       1,
+		},
+		{
+			name: "version not on allowlist",
+			cfg: &UpdateConfig{
+				Version: updateConfigVersion,
+				Kind:    updateConfigKind,
+				Spec: UpdateSpec{
+					AllowedVersions: []string{"16.2.0"},
+				},
+				Status: UpdateStatus{
+					ActiveVersion: "old-version",
+				},
+			},
+
+			errMatch: "allowed_versions",
+		},
 		{
 			name: "insecure URL",
 			cfg: &UpdateConfig{
@@ -296,6 +411,70 @@ func TestUpdater_Enable(t *testing.T) {
 			syncCalls:         1,
 			reloadCalls:       1,
 		},
+		{
+			name: "downgrade allowed",
+			cfg: &UpdateConfig{
+				Version: updateConfigVersion,
+				Kind:    updateConfigKind,
+				Status: UpdateStatus{
+					ActiveVersion: "17.0.0",
+				},
+			},
+			userCfg: OverrideConfig{
+				AllowDowngrade: true,
+			},
+
+			installedVersion:  "16.3.0",
+			installedTemplate: cdnURITemplate,
+			syncCalls:         1,
+			reloadCalls:       1,
+		},
+		{
+			name: "downgrade refused still enables",
+			cfg: &UpdateConfig{
+				Version: updateConfigVersion,
+				Kind:    updateConfigKind,
+				Status: UpdateStatus{
+					ActiveVersion: "17.0.0",
+				},
+			},
+
+			notNeeded: true,
+		},
+		{
+			name: "flags forced with version",
+			userCfg: OverrideConfig{
+				ForceVersion: "new-version",
+				Enterprise:   true,
+				FIPS:         true,
+			},
+			flags: FlagEnterprise | FlagFIPS,
+
+			installedVersion:  "new-version",
+			installedTemplate: cdnURITemplate,
+			syncCalls:         1,
+			reloadCalls:       1,
+		},
+		{
+			name: "forced flags match cluster",
+			userCfg: OverrideConfig{
+				Enterprise: true,
+			},
+			flags: FlagEnterprise,
+
+			installedVersion:  "16.3.0",
+			installedTemplate: cdnURITemplate,
+			syncCalls:         1,
+			reloadCalls:       1,
+		},
+		{
+			name: "forced flags contradict cluster",
+			userCfg: OverrideConfig{
+				FIPS: true,
+			},
+
+			errMatch: "contradict",
+		},
 		{
 			name:     "invalid metadata",
 			cfg:      &UpdateConfig{},
@@ -323,6 +502,27 @@ func TestUpdater_Enable(t *testing.T) {
 			revertCalls:       1,
 			errMatch:          "reload error",
 		},
+		{
+			name:        "health check passes",
+			healthCheck: true,
+
+			installedVersion:  "16.3.0",
+			installedTemplate: cdnURITemplate,
+			syncCalls:         1,
+			reloadCalls:       1,
+		},
+		{
+			name:           "health check fails",
+			healthCheck:    true,
+			healthCheckErr: errors.New("health check error"),
+
+			installedVersion:  "16.3.0",
+			installedTemplate: cdnURITemplate,
+			syncCalls:         2,
+			reloadCalls:       2,
+			revertCalls:       1,
+			errMatch:          "health check error",
+		},
 	}
 
 	for _, tt := range tests {
@@ -411,6 +611,12 @@ func TestUpdater_Enable(t *testing.T) {
 				},
 			}
 
+			if tt.healthCheck {
+				updater.HealthCheck = func(_ context.Context) error {
+					return tt.healthCheckErr
+				}
+			}
+
 			ctx := context.Background()
 			err = updater.Enable(ctx, tt.userCfg)
 			if tt.errMatch != "" {
@@ -418,7 +624,11 @@ func TestUpdater_Enable(t *testing.T) {
 				assert.Contains(t, err.Error(), tt.errMatch)
 				return
 			}
-			require.NoError(t, err)
+			if tt.notNeeded {
+				require.ErrorIs(t, err, ErrNotNeeded)
+			} else {
+				require.NoError(t, err)
+			}
 			require.Equal(t, tt.installedVersion, installedVersion)
 			require.Equal(t, tt.installedTemplate, installedTemplate)
 			require.Equal(t, tt.installedVersion, linkedVersion)
@@ -441,44 +651,2429 @@ func TestUpdater_Enable(t *testing.T) {
 	}
 }
 
-var serverRegexp = regexp.MustCompile("127.0.0.1:[0-9]+")
+func TestUpdater_Update(t *testing.T) {
+	t.Parallel()
 
-func blankTestAddr(s []byte) []byte {
-	return serverRegexp.ReplaceAll(s, []byte("localhost"))
-}
+	tests := []struct {
+		name string
+		cfg  *UpdateConfig // nil -> file not present
 
-type testInstaller struct {
-	FuncInstall func(ctx context.Context, version, template string, flags InstallFlags) error
-	FuncRemove  func(ctx context.Context, version string) error
-	FuncLink    func(ctx context.Context, version string) (revert func(context.Context) bool, err error)
-	FuncList    func(ctx context.Context) (versions []string, err error)
-}
+		installedVersion string
+		syncCalls        int
+		reloadCalls      int
+		notNeeded        bool
+		errMatch         string
+	}{
+		{
+			name: "updates enabled",
+			cfg: &UpdateConfig{
+				Version: updateConfigVersion,
+				Kind:    updateConfigKind,
+				Spec: UpdateSpec{
+					Enabled: true,
+				},
+				Status: UpdateStatus{
+					ActiveVersion: "old-version",
+				},
+			},
 
-func (ti *testInstaller) Install(ctx context.Context, version, template string, flags InstallFlags) error {
-	return ti.FuncInstall(ctx, version, template, flags)
-}
+			installedVersion: "16.3.0",
+			syncCalls:        1,
+			reloadCalls:      1,
+		},
+		{
+			name: "updates disabled",
+			cfg: &UpdateConfig{
+				Version: updateConfigVersion,
+				Kind:    updateConfigKind,
+				Spec: UpdateSpec{
+					Enabled: false,
+				},
+				Status: UpdateStatus{
+					ActiveVersion: "old-version",
+				},
+			},
 
-func (ti *testInstaller) Remove(ctx context.Context, version string) error {
-	return ti.FuncRemove(ctx, version)
-}
+			notNeeded: true,
+		},
+		{
+			name: "config does not exist",
 
-func (ti *testInstaller) Link(ctx context.Context, version string) (revert func(context.Context) bool, err error) {
-	return ti.FuncLink(ctx, version)
-}
+			notNeeded: true,
+		},
+		{
+			name: "downgrade refused",
+			cfg: &UpdateConfig{
+				Version: updateConfigVersion,
+				Kind:    updateConfigKind,
+				Spec: UpdateSpec{
+					Enabled: true,
+				},
+				Status: UpdateStatus{
+					ActiveVersion: "16.4.0",
+				},
+			},
 
-func (ti *testInstaller) List(ctx context.Context) (versions []string, err error) {
-	return ti.FuncList(ctx)
-}
+			notNeeded: true,
+		},
+		{
+			name: "downgrade from pre-release refused",
+			cfg: &UpdateConfig{
+				Version: updateConfigVersion,
+				Kind:    updateConfigKind,
+				Spec: UpdateSpec{
+					Enabled: true,
+				},
+				Status: UpdateStatus{
+					ActiveVersion: "16.3.1-beta.1",
+				},
+			},
 
-type testProcess struct {
-	FuncReload func(ctx context.Context) error
-	FuncSync   func(ctx context.Context) error
-}
+			notNeeded: true,
+		},
+		{
+			name: "upgrade from pre-release",
+			cfg: &UpdateConfig{
+				Version: updateConfigVersion,
+				Kind:    updateConfigKind,
+				Spec: UpdateSpec{
+					Enabled: true,
+				},
+				Status: UpdateStatus{
+					ActiveVersion: "16.3.0-rc.1",
+				},
+			},
 
-func (tp *testProcess) Reload(ctx context.Context) error {
-	return tp.FuncReload(ctx)
-}
+			installedVersion: "16.3.0",
+			syncCalls:        1,
+			reloadCalls:      1,
+		},
+		{
+			name: "equal version revalidated",
+			cfg: &UpdateConfig{
+				Version: updateConfigVersion,
+				Kind:    updateConfigKind,
+				Spec: UpdateSpec{
+					Enabled: true,
+				},
+				Status: UpdateStatus{
+					ActiveVersion: "16.3.0",
+				},
+			},
 
-func (tp *testProcess) Sync(ctx context.Context) error {
-	return tp.FuncSync(ctx)
+			installedVersion: "16.3.0",
+			syncCalls:        1,
+			reloadCalls:      0,
+		},
+		{
+			name: "invalid metadata",
+			cfg: &UpdateConfig{
+				Spec: UpdateSpec{
+					Enabled: true,
+				},
+			},
+			errMatch: "invalid",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			cfgPath := filepath.Join(dir, "update.yaml")
+
+			server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				config := webclient.PingResponse{
+					AutoUpdate: webclient.AutoUpdateSettings{
+						AgentVersion:    "16.3.0",
+						AgentAutoUpdate: true,
+					},
+				}
+				err := json.NewEncoder(w).Encode(config)
+				require.NoError(t, err)
+			}))
+			t.Cleanup(server.Close)
+
+			// Create config file only if provided in test case
+			if tt.cfg != nil {
+				tt.cfg.Spec.Proxy = strings.TrimPrefix(server.URL, "https://")
+				b, err := yaml.Marshal(tt.cfg)
+				require.NoError(t, err)
+				err = os.WriteFile(cfgPath, b, 0600)
+				require.NoError(t, err)
+			}
+
+			updater, err := NewLocalUpdater(LocalUpdaterConfig{
+				InsecureSkipVerify: true,
+				VersionsDir:        dir,
+			})
+			require.NoError(t, err)
+
+			var installedVersion string
+			updater.Installer = &testInstaller{
+				FuncInstall: func(_ context.Context, version, template string, flags InstallFlags) error {
+					installedVersion = version
+					return nil
+				},
+				FuncLink: func(_ context.Context, version string) (revert func(context.Context) bool, err error) {
+					return func(_ context.Context) bool { return true }, nil
+				},
+				FuncList: func(_ context.Context) (versions []string, err error) {
+					return []string{"old"}, nil
+				},
+				FuncRemove: func(_ context.Context, version string) error {
+					return nil
+				},
+			}
+			var (
+				syncCalls   int
+				reloadCalls int
+			)
+			updater.Process = &testProcess{
+				FuncSync: func(_ context.Context) error {
+					syncCalls++
+					return nil
+				},
+				FuncReload: func(_ context.Context) error {
+					reloadCalls++
+					return nil
+				},
+			}
+
+			err = updater.Update(context.Background())
+			if tt.errMatch != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.errMatch)
+				return
+			}
+			if tt.notNeeded {
+				require.ErrorIs(t, err, ErrNotNeeded)
+				require.Empty(t, installedVersion)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.installedVersion, installedVersion)
+			require.Equal(t, tt.syncCalls, syncCalls)
+			require.Equal(t, tt.reloadCalls, reloadCalls)
+		})
+	}
+}
+
+func TestUpdater_Uninstall(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name          string
+		activeVersion string
+		linkedVersion string
+		stopErr       error
+		unlinkErr     error
+
+		unlinkedVersion string
+		stopCalls       int
+		syncCalls       int
+		notNeeded       bool
+		errMatch        string
+	}{
+		{
+			name:          "linked version unlinked",
+			activeVersion: "16.3.0",
+			linkedVersion: "16.3.0",
+
+			unlinkedVersion: "16.3.0",
+			stopCalls:       1,
+			syncCalls:       1,
+		},
+		{
+			name:          "externally linked version preferred",
+			activeVersion: "16.3.0",
+			linkedVersion: "16.4.0",
+
+			unlinkedVersion: "16.4.0",
+			stopCalls:       1,
+			syncCalls:       1,
+		},
+		{
+			name:          "already stopped service tolerated",
+			activeVersion: "16.3.0",
+			linkedVersion: "16.3.0",
+			stopErr:       ErrNotNeeded,
+
+			unlinkedVersion: "16.3.0",
+			stopCalls:       1,
+			syncCalls:       1,
+		},
+		{
+			name:          "systemd not supported tolerated",
+			activeVersion: "16.3.0",
+			linkedVersion: "16.3.0",
+			stopErr:       ErrNotSupported,
+
+			unlinkedVersion: "16.3.0",
+			stopCalls:       1,
+			syncCalls:       1,
+		},
+		{
+			name:          "stop failure aborts uninstall",
+			activeVersion: "16.3.0",
+			linkedVersion: "16.3.0",
+			stopErr:       errors.New("stop failed"),
+
+			stopCalls: 1,
+			errMatch:  "failed to stop",
+		},
+		{
+			name:          "status version used without links",
+			activeVersion: "16.3.0",
+			unlinkErr:     ErrNotNeeded,
+
+			unlinkedVersion: "16.3.0",
+			stopCalls:       1,
+			notNeeded:       true,
+		},
+		{
+			name: "nothing linked or recorded",
+
+			notNeeded: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			cfgPath := filepath.Join(dir, "update.yaml")
+
+			b, err := yaml.Marshal(&UpdateConfig{
+				Version: updateConfigVersion,
+				Kind:    updateConfigKind,
+				Status: UpdateStatus{
+					ActiveVersion: tt.activeVersion,
+				},
+			})
+			require.NoError(t, err)
+			err = os.WriteFile(cfgPath, b, 0600)
+			require.NoError(t, err)
+
+			updater, err := NewLocalUpdater(LocalUpdaterConfig{
+				InsecureSkipVerify: true,
+				VersionsDir:        dir,
+			})
+			require.NoError(t, err)
+
+			var unlinkedVersion string
+			updater.Installer = &testInstaller{
+				FuncLinkedVersion: func(_ context.Context) (version string, err error) {
+					return tt.linkedVersion, nil
+				},
+				FuncUnlink: func(_ context.Context, version string) error {
+					unlinkedVersion = version
+					return tt.unlinkErr
+				},
+			}
+			var stopCalls, syncCalls int
+			updater.Process = &testProcess{
+				FuncStop: func(_ context.Context) error {
+					stopCalls++
+					return tt.stopErr
+				},
+				FuncSync: func(_ context.Context) error {
+					syncCalls++
+					return nil
+				},
+			}
+
+			err = updater.Uninstall(context.Background())
+			switch {
+			case tt.errMatch != "":
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.errMatch)
+			case tt.notNeeded:
+				require.ErrorIs(t, err, ErrNotNeeded)
+			default:
+				require.NoError(t, err)
+			}
+			require.Equal(t, tt.unlinkedVersion, unlinkedVersion)
+			require.Equal(t, tt.stopCalls, stopCalls)
+			require.Equal(t, tt.syncCalls, syncCalls)
+		})
+	}
+}
+
+func TestUpdater_InstallHooks(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	cfgPath := filepath.Join(dir, "update.yaml")
+	outFile := filepath.Join(t.TempDir(), "hooks.txt")
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err := json.NewEncoder(w).Encode(webclient.PingResponse{
+			AutoUpdate: webclient.AutoUpdateSettings{
+				AgentVersion: "16.3.0",
+			},
+		})
+		require.NoError(t, err)
+	}))
+	t.Cleanup(server.Close)
+
+	b, err := yaml.Marshal(&UpdateConfig{
+		Version: updateConfigVersion,
+		Kind:    updateConfigKind,
+		Spec: UpdateSpec{
+			Proxy: strings.TrimPrefix(server.URL, "https://"),
+		},
+		Status: UpdateStatus{
+			ActiveVersion: "old-version",
+		},
+	})
+	require.NoError(t, err)
+	err = os.WriteFile(cfgPath, b, 0600)
+	require.NoError(t, err)
+
+	// Each hook appends its name and environment to outFile.
+	hook := func(name string) []string {
+		return []string{"sh", "-c", fmt.Sprintf("echo %s $TELEPORT_UPDATE_VERSION $TELEPORT_UPDATE_VERSION_PATH >> %s", name, outFile)}
+	}
+	updater, err := NewLocalUpdater(LocalUpdaterConfig{
+		InsecureSkipVerify: true,
+		VersionsDir:        dir,
+		PreInstall:         hook("pre-install"),
+		PostInstall:        hook("post-install"),
+		PostReload:         hook("post-reload"),
+	})
+	require.NoError(t, err)
+
+	var installCalls int
+	updater.Installer = &testInstaller{
+		FuncInstall: func(_ context.Context, version, template string, flags InstallFlags) error {
+			installCalls++
+			return nil
+		},
+		FuncLink: func(_ context.Context, version string) (revert func(context.Context) bool, err error) {
+			return func(_ context.Context) bool { return true }, nil
+		},
+		FuncList: func(_ context.Context) (versions []string, err error) {
+			return nil, nil
+		},
+		FuncRemove: func(_ context.Context, version string) error {
+			return nil
+		},
+	}
+	updater.Process = &testProcess{
+		FuncSync:   func(_ context.Context) error { return nil },
+		FuncReload: func(_ context.Context) error { return nil },
+	}
+
+	err = updater.Enable(context.Background(), OverrideConfig{})
+	require.NoError(t, err)
+	require.Equal(t, 1, installCalls)
+
+	versionPath := filepath.Join(dir, "16.3.0")
+	out, err := os.ReadFile(outFile)
+	require.NoError(t, err)
+	require.Equal(t, fmt.Sprintf("pre-install 16.3.0 %[1]s\npost-install 16.3.0 %[1]s\npost-reload 16.3.0 %[1]s\n", versionPath), string(out))
+
+	// A failing hook aborts the operation before the next stage.
+	updater.PreInstall = []string{"sh", "-c", "exit 7"}
+	err = updater.Enable(context.Background(), OverrideConfig{})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "pre-install hook failed")
+	require.Equal(t, 1, installCalls)
+}
+
+func TestUpdater_StageErrors(t *testing.T) {
+	t.Parallel()
+
+	stageErr := errors.New("stage failed")
+
+	tests := []struct {
+		name string
+
+		installErr error
+		linkErr    error
+		syncErr    error
+		reloadErr  error
+
+		version func(err error) string // extracts the version from the typed error
+	}{
+		{
+			name:       "install",
+			installErr: stageErr,
+			version: func(err error) string {
+				var e *InstallError
+				require.ErrorAs(t, err, &e)
+				return e.Version
+			},
+		},
+		{
+			name:    "link",
+			linkErr: stageErr,
+			version: func(err error) string {
+				var e *LinkError
+				require.ErrorAs(t, err, &e)
+				return e.Version
+			},
+		},
+		{
+			name:    "sync",
+			syncErr: stageErr,
+			version: func(err error) string {
+				var e *SyncError
+				require.ErrorAs(t, err, &e)
+				return e.Version
+			},
+		},
+		{
+			name:      "reload",
+			reloadErr: stageErr,
+			version: func(err error) string {
+				var e *ReloadError
+				require.ErrorAs(t, err, &e)
+				return e.Version
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			cfgPath := filepath.Join(dir, "update.yaml")
+
+			server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				err := json.NewEncoder(w).Encode(webclient.PingResponse{
+					AutoUpdate: webclient.AutoUpdateSettings{
+						AgentVersion: "16.3.0",
+					},
+				})
+				require.NoError(t, err)
+			}))
+			t.Cleanup(server.Close)
+
+			b, err := yaml.Marshal(&UpdateConfig{
+				Version: updateConfigVersion,
+				Kind:    updateConfigKind,
+				Spec: UpdateSpec{
+					Proxy: strings.TrimPrefix(server.URL, "https://"),
+				},
+				Status: UpdateStatus{
+					ActiveVersion: "old-version",
+				},
+			})
+			require.NoError(t, err)
+			err = os.WriteFile(cfgPath, b, 0600)
+			require.NoError(t, err)
+
+			updater, err := NewLocalUpdater(LocalUpdaterConfig{
+				InsecureSkipVerify: true,
+				VersionsDir:        dir,
+			})
+			require.NoError(t, err)
+
+			updater.Installer = &testInstaller{
+				FuncInstall: func(_ context.Context, version, template string, flags InstallFlags) error {
+					return tt.installErr
+				},
+				FuncLink: func(_ context.Context, version string) (revert func(context.Context) bool, err error) {
+					return func(_ context.Context) bool { return true }, tt.linkErr
+				},
+				FuncList: func(_ context.Context) (versions []string, err error) {
+					return nil, nil
+				},
+				FuncRemove: func(_ context.Context, version string) error {
+					return nil
+				},
+			}
+			updater.Process = &testProcess{
+				FuncSync: func(_ context.Context) error {
+					return tt.syncErr
+				},
+				FuncReload: func(_ context.Context) error {
+					return tt.reloadErr
+				},
+			}
+
+			err = updater.Enable(context.Background(), OverrideConfig{})
+			require.Error(t, err)
+			require.ErrorIs(t, err, stageErr)
+			require.Equal(t, "16.3.0", tt.version(err))
+		})
+	}
+}
+
+func TestUpdater_EnableRetry(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	cfgPath := filepath.Join(dir, "update.yaml")
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err := json.NewEncoder(w).Encode(webclient.PingResponse{
+			AutoUpdate: webclient.AutoUpdateSettings{
+				AgentVersion: "16.3.0",
+			},
+		})
+		require.NoError(t, err)
+	}))
+	t.Cleanup(server.Close)
+
+	b, err := yaml.Marshal(&UpdateConfig{
+		Version: updateConfigVersion,
+		Kind:    updateConfigKind,
+		Spec: UpdateSpec{
+			Proxy: strings.TrimPrefix(server.URL, "https://"),
+		},
+		Status: UpdateStatus{
+			ActiveVersion: "old-version",
+		},
+	})
+	require.NoError(t, err)
+	err = os.WriteFile(cfgPath, b, 0600)
+	require.NoError(t, err)
+
+	updater, err := NewLocalUpdater(LocalUpdaterConfig{
+		InsecureSkipVerify: true,
+		VersionsDir:        dir,
+		EnableRetries:      1,
+	})
+	require.NoError(t, err)
+
+	var installCalls int
+	updater.Installer = &testInstaller{
+		FuncInstall: func(_ context.Context, version, template string, flags InstallFlags) error {
+			installCalls++
+			return nil
+		},
+		FuncLink: func(_ context.Context, version string) (revert func(context.Context) bool, err error) {
+			return func(_ context.Context) bool { return true }, nil
+		},
+		FuncList: func(_ context.Context) (versions []string, err error) {
+			return []string{"16.3.0"}, nil
+		},
+		FuncRemove: func(_ context.Context, version string) error {
+			return nil
+		},
+	}
+	var reloadCalls int
+	updater.Process = &testProcess{
+		FuncSync: func(_ context.Context) error {
+			return nil
+		},
+		FuncReload: func(_ context.Context) error {
+			reloadCalls++
+			if reloadCalls == 1 {
+				return errors.New("reload error")
+			}
+			return nil
+		},
+	}
+
+	// The first attempt fails at reload and is reverted; the retry reuses the
+	// installed artifact and succeeds.
+	err = updater.Enable(context.Background(), OverrideConfig{})
+	require.NoError(t, err)
+	require.Equal(t, 2, installCalls)
+	require.Equal(t, 3, reloadCalls) // failed reload + revert + successful reload
+}
+
+func TestUpdater_StagedServiceRollout(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		failSecond bool
+
+		reloadCalls []int
+		errMatch    string
+	}{
+		{
+			name:        "all services updated",
+			reloadCalls: []int{1, 1, 1},
+		},
+		{
+			name:       "rollout halts on failed service",
+			failSecond: true,
+
+			// The first service is reloaded again to revert it, and the
+			// third service is never moved to the new version.
+			reloadCalls: []int{2, 2, 0},
+			errMatch:    "reload error",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			cfgPath := filepath.Join(dir, "update.yaml")
+
+			server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				err := json.NewEncoder(w).Encode(webclient.PingResponse{
+					AutoUpdate: webclient.AutoUpdateSettings{
+						AgentVersion: "16.3.0",
+					},
+				})
+				require.NoError(t, err)
+			}))
+			t.Cleanup(server.Close)
+
+			b, err := yaml.Marshal(&UpdateConfig{
+				Version: updateConfigVersion,
+				Kind:    updateConfigKind,
+				Spec: UpdateSpec{
+					Proxy: strings.TrimPrefix(server.URL, "https://"),
+				},
+				Status: UpdateStatus{
+					ActiveVersion: "old-version",
+				},
+			})
+			require.NoError(t, err)
+			err = os.WriteFile(cfgPath, b, 0600)
+			require.NoError(t, err)
+
+			updater, err := NewLocalUpdater(LocalUpdaterConfig{
+				InsecureSkipVerify: true,
+				VersionsDir:        dir,
+			})
+			require.NoError(t, err)
+			updater.Installer = &testInstaller{
+				FuncInstall: func(_ context.Context, version, template string, flags InstallFlags) error {
+					return nil
+				},
+				FuncLink: func(_ context.Context, version string) (revert func(context.Context) bool, err error) {
+					return func(_ context.Context) bool { return true }, nil
+				},
+				FuncList: func(_ context.Context) (versions []string, err error) {
+					return []string{"16.3.0"}, nil
+				},
+				FuncRemove: func(_ context.Context, version string) error {
+					return nil
+				},
+			}
+
+			reloadCalls := make([]int, 3)
+			procs := make([]Process, 3)
+			for i := range procs {
+				i := i
+				procs[i] = &testProcess{
+					FuncSync: func(_ context.Context) error {
+						return nil
+					},
+					FuncReload: func(_ context.Context) error {
+						reloadCalls[i]++
+						if i == 1 && tt.failSecond && reloadCalls[i] == 1 {
+							return errors.New("reload error")
+						}
+						return nil
+					},
+				}
+			}
+			updater.Process = procs[0]
+			updater.ExtraProcesses = procs[1:]
+
+			err = updater.Enable(context.Background(), OverrideConfig{})
+			if tt.errMatch != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.errMatch)
+			} else {
+				require.NoError(t, err)
+			}
+			require.Equal(t, tt.reloadCalls, reloadCalls)
+		})
+	}
+}
+
+func TestUpdater_GroupRolloutGate(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	cfgPath := filepath.Join(dir, "update.yaml")
+
+	var agentAutoUpdate bool
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err := json.NewEncoder(w).Encode(webclient.PingResponse{
+			AutoUpdate: webclient.AutoUpdateSettings{
+				AgentVersion:    "16.3.0",
+				AgentAutoUpdate: agentAutoUpdate,
+			},
+		})
+		require.NoError(t, err)
+	}))
+	t.Cleanup(server.Close)
+
+	b, err := yaml.Marshal(&UpdateConfig{
+		Version: updateConfigVersion,
+		Kind:    updateConfigKind,
+		Spec: UpdateSpec{
+			Proxy:   strings.TrimPrefix(server.URL, "https://"),
+			Group:   "staging",
+			Enabled: true,
+		},
+		Status: UpdateStatus{
+			ActiveVersion: "old-version",
+		},
+	})
+	require.NoError(t, err)
+	err = os.WriteFile(cfgPath, b, 0600)
+	require.NoError(t, err)
+
+	updater, err := NewLocalUpdater(LocalUpdaterConfig{
+		InsecureSkipVerify: true,
+		VersionsDir:        dir,
+	})
+	require.NoError(t, err)
+
+	var installedVersion string
+	updater.Installer = &testInstaller{
+		FuncInstall: func(_ context.Context, version, template string, flags InstallFlags) error {
+			installedVersion = version
+			return nil
+		},
+		FuncLink: func(_ context.Context, version string) (revert func(context.Context) bool, err error) {
+			return func(_ context.Context) bool { return true }, nil
+		},
+		FuncList: func(_ context.Context) (versions []string, err error) {
+			return []string{"16.3.0"}, nil
+		},
+		FuncRemove: func(_ context.Context, version string) error {
+			return nil
+		},
+	}
+	updater.Process = &testProcess{
+		FuncSync: func(_ context.Context) error {
+			return nil
+		},
+		FuncReload: func(_ context.Context) error {
+			return nil
+		},
+	}
+	ctx := context.Background()
+
+	// The update is deferred while the cluster has not scheduled the group.
+	err = updater.Update(ctx)
+	require.ErrorIs(t, err, ErrNotNeeded)
+	require.Empty(t, installedVersion)
+
+	// Once the group is scheduled, the update proceeds.
+	agentAutoUpdate = true
+	err = updater.Update(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "16.3.0", installedVersion)
+}
+
+func TestUpdater_FlagChangeReinstall(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	cfgPath := filepath.Join(dir, "update.yaml")
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err := json.NewEncoder(w).Encode(webclient.PingResponse{
+			AutoUpdate: webclient.AutoUpdateSettings{
+				AgentVersion:    "16.3.0",
+				AgentAutoUpdate: true,
+			},
+			FIPS: true,
+		})
+		require.NoError(t, err)
+	}))
+	t.Cleanup(server.Close)
+
+	b, err := yaml.Marshal(&UpdateConfig{
+		Version: updateConfigVersion,
+		Kind:    updateConfigKind,
+		Spec: UpdateSpec{
+			Proxy:   strings.TrimPrefix(server.URL, "https://"),
+			Enabled: true,
+		},
+		Status: UpdateStatus{
+			ActiveVersion: "16.3.0",
+			BackupVersion: "16.2.0",
+		},
+	})
+	require.NoError(t, err)
+	err = os.WriteFile(cfgPath, b, 0600)
+	require.NoError(t, err)
+
+	updater, err := NewLocalUpdater(LocalUpdaterConfig{
+		InsecureSkipVerify: true,
+		VersionsDir:        dir,
+	})
+	require.NoError(t, err)
+
+	var (
+		installedVersion string
+		installedFlags   InstallFlags
+	)
+	updater.Installer = &testInstaller{
+		FuncInstall: func(_ context.Context, version, template string, flags InstallFlags) error {
+			installedVersion = version
+			installedFlags = flags
+			return nil
+		},
+		FuncLink: func(_ context.Context, version string) (revert func(context.Context) bool, err error) {
+			return func(_ context.Context) bool { return true }, nil
+		},
+		FuncList: func(_ context.Context) (versions []string, err error) {
+			return []string{"16.2.0", "16.3.0"}, nil
+		},
+		FuncRemove: func(_ context.Context, version string) error {
+			return nil
+		},
+	}
+	var (
+		syncCalls   int
+		reloadCalls int
+	)
+	updater.Process = &testProcess{
+		FuncSync: func(_ context.Context) error {
+			syncCalls++
+			return nil
+		},
+		FuncReload: func(_ context.Context) error {
+			reloadCalls++
+			return nil
+		},
+	}
+
+	// A FIPS toggle at an equal version reinstalls and reloads Teleport,
+	// but does not record a version change.
+	err = updater.Update(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "16.3.0", installedVersion)
+	require.Equal(t, FlagFIPS, installedFlags)
+	require.Equal(t, 1, syncCalls)
+	require.Equal(t, 1, reloadCalls)
+
+	cfg, err := readConfig(cfgPath)
+	require.NoError(t, err)
+	require.Equal(t, "16.3.0", cfg.Status.ActiveVersion)
+	require.Equal(t, "16.2.0", cfg.Status.BackupVersion)
+	require.Equal(t, FlagFIPS, cfg.Status.ActiveFlags)
+}
+
+func TestUpdater_LinkDrift(t *testing.T) {
+	t.Parallel()
+
+	// The active link was externally repointed to 16.4.0, while status still
+	// records 16.2.0 as active. The cluster desires 16.4.0.
+	tests := []struct {
+		name   string
+		policy LinkDriftPolicy
+
+		linkVersions  []string
+		reloadCalls   int
+		activeVersion string
+		backupVersion string
+	}{
+		{
+			name:   "warn",
+			policy: LinkDriftWarn,
+
+			// The discrepancy is logged, and the update proceeds normally.
+			linkVersions:  []string{"16.4.0"},
+			reloadCalls:   1,
+			activeVersion: "16.4.0",
+			backupVersion: "16.2.0",
+		},
+		{
+			name:   "adopt",
+			policy: LinkDriftAdopt,
+
+			// The linked version is adopted as active, so the desired version
+			// is revalidated without a reload or a backup version change.
+			linkVersions:  []string{"16.4.0"},
+			reloadCalls:   0,
+			activeVersion: "16.4.0",
+		},
+		{
+			name:   "repair",
+			policy: LinkDriftRepair,
+
+			// The link is restored to the version recorded in status before
+			// the update proceeds normally.
+			linkVersions:  []string{"16.2.0", "16.4.0"},
+			reloadCalls:   1,
+			activeVersion: "16.4.0",
+			backupVersion: "16.2.0",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			cfgPath := filepath.Join(dir, "update.yaml")
+
+			server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				err := json.NewEncoder(w).Encode(webclient.PingResponse{
+					AutoUpdate: webclient.AutoUpdateSettings{
+						AgentVersion:    "16.4.0",
+						AgentAutoUpdate: true,
+					},
+				})
+				require.NoError(t, err)
+			}))
+			t.Cleanup(server.Close)
+
+			b, err := yaml.Marshal(&UpdateConfig{
+				Version: updateConfigVersion,
+				Kind:    updateConfigKind,
+				Spec: UpdateSpec{
+					Proxy:   strings.TrimPrefix(server.URL, "https://"),
+					Enabled: true,
+				},
+				Status: UpdateStatus{
+					ActiveVersion: "16.2.0",
+				},
+			})
+			require.NoError(t, err)
+			err = os.WriteFile(cfgPath, b, 0600)
+			require.NoError(t, err)
+
+			updater, err := NewLocalUpdater(LocalUpdaterConfig{
+				InsecureSkipVerify: true,
+				VersionsDir:        dir,
+				LinkDriftPolicy:    tt.policy,
+			})
+			require.NoError(t, err)
+
+			var linkVersions []string
+			updater.Installer = &testInstaller{
+				FuncInstall: func(_ context.Context, version, template string, flags InstallFlags) error {
+					return nil
+				},
+				FuncLink: func(_ context.Context, version string) (revert func(context.Context) bool, err error) {
+					linkVersions = append(linkVersions, version)
+					return func(_ context.Context) bool { return true }, nil
+				},
+				FuncLinkedVersion: func(_ context.Context) (version string, err error) {
+					return "16.4.0", nil
+				},
+				FuncList: func(_ context.Context) (versions []string, err error) {
+					return []string{"16.2.0", "16.4.0"}, nil
+				},
+				FuncRemove: func(_ context.Context, version string) error {
+					return nil
+				},
+			}
+			var reloadCalls int
+			updater.Process = &testProcess{
+				FuncSync: func(_ context.Context) error {
+					return nil
+				},
+				FuncReload: func(_ context.Context) error {
+					reloadCalls++
+					return nil
+				},
+			}
+
+			err = updater.Update(context.Background())
+			require.NoError(t, err)
+			require.Equal(t, tt.linkVersions, linkVersions)
+			require.Equal(t, tt.reloadCalls, reloadCalls)
+
+			cfg, err := readConfig(cfgPath)
+			require.NoError(t, err)
+			require.Equal(t, tt.activeVersion, cfg.Status.ActiveVersion)
+			require.Equal(t, tt.backupVersion, cfg.Status.BackupVersion)
+		})
+	}
+}
+
+func TestUpdater_ReloadThrottle(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	cfgPath := filepath.Join(dir, "update.yaml")
+
+	var agentVersion string
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err := json.NewEncoder(w).Encode(webclient.PingResponse{
+			AutoUpdate: webclient.AutoUpdateSettings{
+				AgentVersion:    agentVersion,
+				AgentAutoUpdate: true,
+			},
+		})
+		require.NoError(t, err)
+	}))
+	t.Cleanup(server.Close)
+
+	b, err := yaml.Marshal(&UpdateConfig{
+		Version: updateConfigVersion,
+		Kind:    updateConfigKind,
+		Spec: UpdateSpec{
+			Proxy:   strings.TrimPrefix(server.URL, "https://"),
+			Enabled: true,
+		},
+		Status: UpdateStatus{
+			ActiveVersion: "old-version",
+		},
+	})
+	require.NoError(t, err)
+	err = os.WriteFile(cfgPath, b, 0600)
+	require.NoError(t, err)
+
+	updater, err := NewLocalUpdater(LocalUpdaterConfig{
+		InsecureSkipVerify: true,
+		VersionsDir:        dir,
+		MinReloadInterval:  time.Hour,
+	})
+	require.NoError(t, err)
+
+	var reloadCalls int
+	updater.Installer = &testInstaller{
+		FuncInstall: func(_ context.Context, version, template string, flags InstallFlags) error {
+			return nil
+		},
+		FuncLink: func(_ context.Context, version string) (revert func(context.Context) bool, err error) {
+			return func(_ context.Context) bool { return true }, nil
+		},
+		FuncList: func(_ context.Context) (versions []string, err error) {
+			return nil, nil
+		},
+		FuncRemove: func(_ context.Context, version string) error {
+			return nil
+		},
+	}
+	updater.Process = &testProcess{
+		FuncSync: func(_ context.Context) error { return nil },
+		FuncReload: func(_ context.Context) error {
+			reloadCalls++
+			return nil
+		},
+	}
+
+	ctx := context.Background()
+
+	// First update reloads and records the reload time.
+	agentVersion = "16.3.0"
+	err = updater.Update(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 1, reloadCalls)
+
+	// A rapid successive version change is deferred.
+	agentVersion = "16.4.0"
+	err = updater.Update(ctx)
+	require.ErrorIs(t, err, ErrNotNeeded)
+	require.Equal(t, 1, reloadCalls)
+
+	// Re-validating the same version is not throttled.
+	agentVersion = "16.3.0"
+	err = updater.Update(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 1, reloadCalls)
+}
+
+func TestUpdater_ProxyPersist(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		persist ProxyPersistMode
+
+		persistedProxy string // "server" -> the overridden proxy
+	}{
+		{
+			name:    "override persisted by default",
+			persist: ProxyPersistAlways,
+
+			persistedProxy: "server",
+		},
+		{
+			name:    "override used for one run",
+			persist: ProxyPersistNever,
+
+			persistedProxy: "proxy.example.com",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			cfgPath := filepath.Join(dir, "update.yaml")
+
+			server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				err := json.NewEncoder(w).Encode(webclient.PingResponse{
+					AutoUpdate: webclient.AutoUpdateSettings{
+						AgentVersion: "16.3.0",
+					},
+				})
+				require.NoError(t, err)
+			}))
+			t.Cleanup(server.Close)
+
+			b, err := yaml.Marshal(&UpdateConfig{
+				Version: updateConfigVersion,
+				Kind:    updateConfigKind,
+				Spec: UpdateSpec{
+					Proxy: "proxy.example.com",
+				},
+			})
+			require.NoError(t, err)
+			err = os.WriteFile(cfgPath, b, 0600)
+			require.NoError(t, err)
+
+			updater, err := NewLocalUpdater(LocalUpdaterConfig{
+				InsecureSkipVerify: true,
+				VersionsDir:        dir,
+			})
+			require.NoError(t, err)
+
+			updater.Installer = &testInstaller{
+				FuncInstall: func(_ context.Context, version, template string, flags InstallFlags) error {
+					return nil
+				},
+				FuncLink: func(_ context.Context, version string) (revert func(context.Context) bool, err error) {
+					return func(_ context.Context) bool { return true }, nil
+				},
+				FuncList: func(_ context.Context) (versions []string, err error) {
+					return nil, nil
+				},
+				FuncRemove: func(_ context.Context, version string) error {
+					return nil
+				},
+			}
+			updater.Process = &testProcess{
+				FuncSync:   func(_ context.Context) error { return nil },
+				FuncReload: func(_ context.Context) error { return nil },
+			}
+
+			overrideProxy := strings.TrimPrefix(server.URL, "https://")
+			err = updater.Enable(context.Background(), OverrideConfig{
+				Proxy:        overrideProxy,
+				ProxyPersist: tt.persist,
+			})
+			require.NoError(t, err)
+
+			cfg, err := readConfig(cfgPath)
+			require.NoError(t, err)
+			want := tt.persistedProxy
+			if want == "server" {
+				want = overrideProxy
+			}
+			require.Equal(t, want, cfg.Spec.Proxy)
+			require.True(t, cfg.Spec.Enabled)
+			require.Equal(t, "16.3.0", cfg.Status.ActiveVersion)
+		})
+	}
+}
+
+func TestUpdater_TeleportConfigProxyFallback(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name           string
+		teleportConfig string // "PROXY_ADDR" is replaced with the test server address
+
+		persisted bool
+		errMatch  string
+	}{
+		{
+			name:           "proxy read from teleport config",
+			teleportConfig: "teleport:\n  proxy_server: PROXY_ADDR\n",
+
+			persisted: true,
+		},
+		{
+			name:           "teleport config without proxy",
+			teleportConfig: "teleport:\n  nodename: agent\n",
+
+			errMatch: "proxy URL must be specified",
+		},
+		{
+			name: "teleport config missing",
+
+			errMatch: "proxy URL must be specified",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			cfgPath := filepath.Join(dir, "update.yaml")
+			teleportConfigPath := filepath.Join(t.TempDir(), "teleport.yaml")
+
+			server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				err := json.NewEncoder(w).Encode(webclient.PingResponse{
+					AutoUpdate: webclient.AutoUpdateSettings{
+						AgentVersion: "16.3.0",
+					},
+				})
+				require.NoError(t, err)
+			}))
+			t.Cleanup(server.Close)
+			proxy := strings.TrimPrefix(server.URL, "https://")
+
+			if tt.teleportConfig != "" {
+				content := strings.ReplaceAll(tt.teleportConfig, "PROXY_ADDR", proxy)
+				err := os.WriteFile(teleportConfigPath, []byte(content), 0600)
+				require.NoError(t, err)
+			}
+
+			updater, err := NewLocalUpdater(LocalUpdaterConfig{
+				InsecureSkipVerify: true,
+				VersionsDir:        dir,
+				TeleportConfigPath: teleportConfigPath,
+			})
+			require.NoError(t, err)
+
+			updater.Installer = &testInstaller{
+				FuncInstall: func(_ context.Context, version, template string, flags InstallFlags) error {
+					return nil
+				},
+				FuncLink: func(_ context.Context, version string) (revert func(context.Context) bool, err error) {
+					return func(_ context.Context) bool { return true }, nil
+				},
+				FuncList: func(_ context.Context) (versions []string, err error) {
+					return nil, nil
+				},
+				FuncRemove: func(_ context.Context, version string) error {
+					return nil
+				},
+			}
+			updater.Process = &testProcess{
+				FuncSync:   func(_ context.Context) error { return nil },
+				FuncReload: func(_ context.Context) error { return nil },
+			}
+
+			err = updater.Enable(context.Background(), OverrideConfig{})
+			if tt.errMatch != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.errMatch)
+				return
+			}
+			require.NoError(t, err)
+
+			cfg, err := readConfig(cfgPath)
+			require.NoError(t, err)
+			if tt.persisted {
+				require.Equal(t, proxy, cfg.Spec.Proxy)
+			}
+			require.Equal(t, "16.3.0", cfg.Status.ActiveVersion)
+		})
+	}
+}
+
+func TestNewLocalUpdater_CAFile(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	t.Cleanup(server.Close)
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+
+	tests := []struct {
+		name string
+		pem  []byte // nil -> file not present
+
+		errMatch string
+	}{
+		{
+			name: "valid bundle",
+			pem:  caPEM,
+		},
+		{
+			name:     "missing file",
+			errMatch: "failed to read CA bundle",
+		},
+		{
+			name:     "no certificates",
+			pem:      []byte("not a certificate"),
+			errMatch: "no certificates found in CA bundle",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			caFile := filepath.Join(t.TempDir(), "ca.pem")
+			if tt.pem != nil {
+				err := os.WriteFile(caFile, tt.pem, 0600)
+				require.NoError(t, err)
+			}
+
+			_, err := NewLocalUpdater(LocalUpdaterConfig{
+				VersionsDir: t.TempDir(),
+				CAFile:      caFile,
+			})
+			if tt.errMatch != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.errMatch)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestUpdater_FailureCooldown(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	cfgPath := filepath.Join(dir, "update.yaml")
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err := json.NewEncoder(w).Encode(webclient.PingResponse{
+			AutoUpdate: webclient.AutoUpdateSettings{
+				AgentVersion:    "16.3.0",
+				AgentAutoUpdate: true,
+			},
+		})
+		require.NoError(t, err)
+	}))
+	t.Cleanup(server.Close)
+
+	b, err := yaml.Marshal(&UpdateConfig{
+		Version: updateConfigVersion,
+		Kind:    updateConfigKind,
+		Spec: UpdateSpec{
+			Proxy:   strings.TrimPrefix(server.URL, "https://"),
+			Enabled: true,
+		},
+		Status: UpdateStatus{
+			ActiveVersion: "old-version",
+		},
+	})
+	require.NoError(t, err)
+	err = os.WriteFile(cfgPath, b, 0600)
+	require.NoError(t, err)
+
+	updater, err := NewLocalUpdater(LocalUpdaterConfig{
+		InsecureSkipVerify: true,
+		VersionsDir:        dir,
+	})
+	require.NoError(t, err)
+
+	var (
+		installCalls int
+		installErr   error
+	)
+	updater.Installer = &testInstaller{
+		FuncInstall: func(_ context.Context, version, template string, flags InstallFlags) error {
+			installCalls++
+			return installErr
+		},
+		FuncLink: func(_ context.Context, version string) (revert func(context.Context) bool, err error) {
+			return func(_ context.Context) bool { return true }, nil
+		},
+		FuncList: func(_ context.Context) (versions []string, err error) {
+			return nil, nil
+		},
+		FuncRemove: func(_ context.Context, version string) error {
+			return nil
+		},
+	}
+	updater.Process = &testProcess{
+		FuncSync:   func(_ context.Context) error { return nil },
+		FuncReload: func(_ context.Context) error { return nil },
+	}
+
+	// setLastFailure rewrites the persisted last failure time, to simulate
+	// the passage of time between scheduled runs.
+	setLastFailure := func(t *testing.T, when time.Time) {
+		t.Helper()
+		cfg, err := readConfig(cfgPath)
+		require.NoError(t, err)
+		cfg.Status.LastFailure = when
+		require.NoError(t, writeConfig(cfgPath, cfg))
+	}
+
+	ctx := context.Background()
+
+	// A failed update is recorded in status.
+	installErr = errors.New("bad version")
+	err = updater.Update(ctx)
+	require.Error(t, err)
+	require.Equal(t, 1, installCalls)
+
+	cfg, err := readConfig(cfgPath)
+	require.NoError(t, err)
+	require.Equal(t, 1, cfg.Status.Failures)
+	require.False(t, cfg.Status.LastFailure.IsZero())
+
+	// The next tick is deferred without contacting the proxy or CDN.
+	err = updater.Update(ctx)
+	require.ErrorIs(t, err, ErrNotNeeded)
+	require.Equal(t, 1, installCalls)
+
+	// After the cooldown elapses, the update is retried and fails again.
+	setLastFailure(t, time.Now().Add(-failureCooldownBase-time.Minute))
+	err = updater.Update(ctx)
+	require.Error(t, err)
+	require.Equal(t, 2, installCalls)
+
+	cfg, err = readConfig(cfgPath)
+	require.NoError(t, err)
+	require.Equal(t, 2, cfg.Status.Failures)
+
+	// The cooldown doubles with each consecutive failure.
+	setLastFailure(t, time.Now().Add(-failureCooldownBase-time.Minute))
+	err = updater.Update(ctx)
+	require.ErrorIs(t, err, ErrNotNeeded)
+	require.Equal(t, 2, installCalls)
+
+	// A success after the cooldown resets the counter.
+	setLastFailure(t, time.Now().Add(-2*failureCooldownBase-time.Minute))
+	installErr = nil
+	err = updater.Update(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 3, installCalls)
+
+	cfg, err = readConfig(cfgPath)
+	require.NoError(t, err)
+	require.Zero(t, cfg.Status.Failures)
+	require.True(t, cfg.Status.LastFailure.IsZero())
+}
+
+func TestFailureCooldown(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range []struct {
+		failures int
+		cooldown time.Duration
+	}{
+		{failures: 1, cooldown: failureCooldownBase},
+		{failures: 2, cooldown: 2 * failureCooldownBase},
+		{failures: 3, cooldown: 4 * failureCooldownBase},
+		{failures: 100, cooldown: failureCooldownMax},
+	} {
+		require.Equal(t, tt.cooldown, failureCooldown(tt.failures), "failures: %d", tt.failures)
+	}
+}
+
+func TestUpdater_ForceVersionFlagMismatch(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		flags       InstallFlags
+		forcedFlags bool
+
+		warnMatch string
+		lookups   int
+	}{
+		{
+			name:    "flags match",
+			lookups: 1,
+		},
+		{
+			name:      "flags mismatch",
+			flags:     FlagEnterprise | FlagFIPS,
+			warnMatch: "differ",
+			lookups:   1,
+		},
+		{
+			name:        "forced flags skip lookup",
+			flags:       FlagEnterprise | FlagFIPS,
+			forcedFlags: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+
+			var lookups int
+			server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				lookups++
+				config := webclient.PingResponse{
+					AutoUpdate: webclient.AutoUpdateSettings{
+						AgentVersion: "16.3.0",
+					},
+				}
+				if tt.flags&FlagEnterprise != 0 {
+					config.Edition = "ent"
+				}
+				config.FIPS = tt.flags&FlagFIPS != 0
+				err := json.NewEncoder(w).Encode(config)
+				require.NoError(t, err)
+			}))
+			t.Cleanup(server.Close)
+
+			updater, err := NewLocalUpdater(LocalUpdaterConfig{
+				InsecureSkipVerify: true,
+				VersionsDir:        dir,
+			})
+			require.NoError(t, err)
+
+			var logs bytes.Buffer
+			updater.Log = slog.New(slog.NewTextHandler(&logs, nil))
+			updater.Installer = &testInstaller{
+				FuncInstall: func(_ context.Context, version, template string, flags InstallFlags) error {
+					return nil
+				},
+				FuncLink: func(_ context.Context, version string) (revert func(context.Context) bool, err error) {
+					return func(_ context.Context) bool { return true }, nil
+				},
+				FuncList: func(_ context.Context) (versions []string, err error) {
+					return nil, nil
+				},
+				FuncRemove: func(_ context.Context, version string) error {
+					return nil
+				},
+			}
+			updater.Process = &testProcess{
+				FuncSync:   func(_ context.Context) error { return nil },
+				FuncReload: func(_ context.Context) error { return nil },
+			}
+
+			override := OverrideConfig{
+				Proxy:        strings.TrimPrefix(server.URL, "https://"),
+				ForceVersion: "16.3.0",
+			}
+			if tt.forcedFlags {
+				override.Enterprise = tt.flags&FlagEnterprise != 0
+				override.FIPS = tt.flags&FlagFIPS != 0
+			}
+			err = updater.Enable(context.Background(), override)
+			require.NoError(t, err)
+
+			if tt.warnMatch != "" {
+				require.Contains(t, logs.String(), tt.warnMatch)
+			} else {
+				require.NotContains(t, logs.String(), "differ")
+			}
+			require.Equal(t, tt.lookups, lookups)
+		})
+	}
+}
+
+func TestUpdater_SelfCheck(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		cfg       *UpdateConfig // nil -> file not present
+		installed []string
+		listErr   error
+		verifyErr error
+
+		errMatches []string
+	}{
+		{
+			name: "consistent state",
+			cfg: &UpdateConfig{
+				Version: updateConfigVersion,
+				Kind:    updateConfigKind,
+				Spec: UpdateSpec{
+					Enabled: true,
+				},
+				Status: UpdateStatus{
+					ActiveVersion: "16.3.0",
+					BackupVersion: "16.2.0",
+				},
+			},
+			installed: []string{"16.2.0", "16.3.0"},
+		},
+		{
+			name: "invalid config",
+			cfg: &UpdateConfig{
+				Kind: "bad-kind",
+			},
+			installed:  []string{"16.3.0"},
+			errMatches: []string{"invalid"},
+		},
+		{
+			name: "missing active and backup versions",
+			cfg: &UpdateConfig{
+				Version: updateConfigVersion,
+				Kind:    updateConfigKind,
+				Status: UpdateStatus{
+					ActiveVersion: "16.3.0",
+					BackupVersion: "16.2.0",
+				},
+			},
+			installed: []string{"16.1.0"},
+			errMatches: []string{
+				`active version "16.3.0" is not installed`,
+				`backup version "16.2.0" is not installed`,
+			},
+		},
+		{
+			name: "corrupted version",
+			cfg: &UpdateConfig{
+				Version: updateConfigVersion,
+				Kind:    updateConfigKind,
+				Status: UpdateStatus{
+					ActiveVersion: "16.3.0",
+				},
+			},
+			installed: []string{"16.3.0"},
+			verifyErr: &VerifyError{Version: "16.3.0", Path: "bin/teleport", Err: errors.New("checksum mismatch")},
+			errMatches: []string{
+				`file "bin/teleport" in version "16.3.0" failed verification`,
+			},
+		},
+		{
+			name: "enabled without active version",
+			cfg: &UpdateConfig{
+				Version: updateConfigVersion,
+				Kind:    updateConfigKind,
+				Spec: UpdateSpec{
+					Enabled: true,
+				},
+			},
+			errMatches: []string{"no active version"},
+		},
+		{
+			name:       "unreadable versions directory",
+			listErr:    errors.New("list error"),
+			errMatches: []string{"list error"},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			cfgPath := filepath.Join(dir, "update.yaml")
+			if tt.cfg != nil {
+				b, err := yaml.Marshal(tt.cfg)
+				require.NoError(t, err)
+				err = os.WriteFile(cfgPath, b, 0600)
+				require.NoError(t, err)
+			}
+
+			updater, err := NewLocalUpdater(LocalUpdaterConfig{
+				InsecureSkipVerify: true,
+				VersionsDir:        dir,
+			})
+			require.NoError(t, err)
+			updater.Installer = &testInstaller{
+				FuncList: func(_ context.Context) (versions []string, err error) {
+					return tt.installed, tt.listErr
+				},
+				FuncVerify: func(_ context.Context, version string) error {
+					return tt.verifyErr
+				},
+			}
+
+			err = updater.SelfCheck(context.Background())
+			if len(tt.errMatches) == 0 {
+				require.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			for _, m := range tt.errMatches {
+				assert.Contains(t, err.Error(), m)
+			}
+		})
+	}
+}
+
+func TestUpdater_Preflight(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name          string
+		proxy         bool
+		serverVersion string
+		diskErr       error
+		syncErr       error
+
+		failedChecks []string
+	}{
+		{
+			name:          "all checks pass",
+			proxy:         true,
+			serverVersion: "16.3.0",
+		},
+		{
+			name:          "disk not writable",
+			proxy:         true,
+			serverVersion: "16.3.0",
+			diskErr:       errors.New("disk error"),
+			failedChecks:  []string{"disk"},
+		},
+		{
+			name:         "proxy not configured",
+			failedChecks: []string{"proxy"},
+		},
+		{
+			name:         "proxy missing version",
+			proxy:        true,
+			failedChecks: []string{"proxy"},
+		},
+		{
+			name:          "process unavailable",
+			proxy:         true,
+			serverVersion: "16.3.0",
+			syncErr:       ErrNotSupported,
+			failedChecks:  []string{"process"},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			cfgPath := filepath.Join(dir, "update.yaml")
+
+			server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				config := webclient.PingResponse{
+					AutoUpdate: webclient.AutoUpdateSettings{
+						AgentVersion: tt.serverVersion,
+					},
+				}
+				err := json.NewEncoder(w).Encode(config)
+				require.NoError(t, err)
+			}))
+			t.Cleanup(server.Close)
+
+			cfg := &UpdateConfig{
+				Version: updateConfigVersion,
+				Kind:    updateConfigKind,
+			}
+			if tt.proxy {
+				cfg.Spec.Proxy = strings.TrimPrefix(server.URL, "https://")
+			}
+			b, err := yaml.Marshal(cfg)
+			require.NoError(t, err)
+			err = os.WriteFile(cfgPath, b, 0600)
+			require.NoError(t, err)
+
+			updater, err := NewLocalUpdater(LocalUpdaterConfig{
+				InsecureSkipVerify: true,
+				VersionsDir:        dir,
+			})
+			require.NoError(t, err)
+			updater.Installer = &testInstaller{
+				FuncCheckDisk: func(_ context.Context) error {
+					return tt.diskErr
+				},
+			}
+			updater.Process = &testProcess{
+				FuncSync: func(_ context.Context) error {
+					return tt.syncErr
+				},
+			}
+
+			report, err := updater.Preflight(context.Background())
+			require.NoError(t, err)
+			require.Len(t, report.Checks, 4)
+			var failed []string
+			for _, check := range report.Checks {
+				if !check.Passed {
+					assert.NotEmpty(t, check.Error)
+					assert.NotEmpty(t, check.Hint)
+					failed = append(failed, check.Name)
+				}
+			}
+			require.Equal(t, tt.failedChecks, failed)
+			require.Equal(t, len(tt.failedChecks) == 0, report.Passed())
+		})
+	}
+}
+
+func TestReadConfig_MigrateV1(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "update.yaml")
+	v1 := `version: v1
+kind: update_config
+spec:
+  proxy: example.com
+  enabled: true
+status:
+  active_version: 16.3.0
+`
+	err := os.WriteFile(path, []byte(v1), 0600)
+	require.NoError(t, err)
+
+	// A v1 config is migrated in-memory to the current schema.
+	cfg, err := readConfig(path)
+	require.NoError(t, err)
+	require.Equal(t, updateConfigVersion, cfg.Version)
+	require.Equal(t, "example.com", cfg.Spec.Proxy)
+	require.True(t, cfg.Spec.Enabled)
+	require.Equal(t, "16.3.0", cfg.Status.ActiveVersion)
+
+	// The migrated config is rewritten at the current version on the next write.
+	err = writeConfig(path, cfg)
+	require.NoError(t, err)
+	b, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(b), "version: "+updateConfigVersion)
+
+	// Unknown schema versions are rejected.
+	err = os.WriteFile(path, []byte("version: v9\nkind: update_config\n"), 0600)
+	require.NoError(t, err)
+	_, err = readConfig(path)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `invalid version "v9"`)
+}
+
+func TestUpdater_Inventory(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	cfgPath := filepath.Join(dir, "update.yaml")
+	b, err := yaml.Marshal(&UpdateConfig{
+		Version: updateConfigVersion,
+		Kind:    updateConfigKind,
+		Status: UpdateStatus{
+			ActiveVersion: "16.3.0",
+			BackupVersion: "16.2.0",
+			ActiveFlags:   FlagFIPS,
+		},
+	})
+	require.NoError(t, err)
+	err = os.WriteFile(cfgPath, b, 0600)
+	require.NoError(t, err)
+
+	updater, err := NewLocalUpdater(LocalUpdaterConfig{
+		InsecureSkipVerify: true,
+		VersionsDir:        dir,
+	})
+	require.NoError(t, err)
+	updater.Installer = &testInstaller{
+		FuncList: func(_ context.Context) (versions []string, err error) {
+			return []string{"16.2.0", "16.3.0"}, nil
+		},
+		FuncStat: func(_ context.Context, version string) (InstallInfo, error) {
+			info := InstallInfo{Version: version, Size: 100}
+			if version == "16.3.0" {
+				info.Flags = FlagFIPS
+				info.Size = 200
+			}
+			return info, nil
+		},
+	}
+
+	inv, err := updater.Inventory(context.Background())
+	require.NoError(t, err)
+
+	hostname, err := os.Hostname()
+	require.NoError(t, err)
+	require.Equal(t, Inventory{
+		Hostname:      hostname,
+		ActiveVersion: "16.3.0",
+		BackupVersion: "16.2.0",
+		ActiveFlags:   FlagFIPS,
+		Versions: []InstallInfo{
+			{Version: "16.2.0", Size: 100},
+			{Version: "16.3.0", Flags: FlagFIPS, Size: 200},
+		},
+	}, inv)
+}
+
+func TestUpdater_ReportUpdates(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		reportCode int
+		installErr error
+
+		reportedVersion string
+		reportedSuccess bool
+		errMatch        string
+	}{
+		{
+			name:       "successful update reported",
+			reportCode: http.StatusOK,
+
+			reportedVersion: "16.3.0",
+			reportedSuccess: true,
+		},
+		{
+			name:       "failed update reported",
+			reportCode: http.StatusOK,
+			installErr: errors.New("install error"),
+
+			reportedVersion: "old-version",
+			errMatch:        "install error",
+		},
+		{
+			name:       "report failure is non-fatal",
+			reportCode: http.StatusInternalServerError,
+
+			reportedVersion: "16.3.0",
+			reportedSuccess: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			cfgPath := filepath.Join(dir, "update.yaml")
+
+			var report *updateReport
+			server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == updateReportPath {
+					report = &updateReport{}
+					require.NoError(t, json.NewDecoder(r.Body).Decode(report))
+					w.WriteHeader(tt.reportCode)
+					return
+				}
+				err := json.NewEncoder(w).Encode(webclient.PingResponse{
+					AutoUpdate: webclient.AutoUpdateSettings{
+						AgentVersion: "16.3.0",
+					},
+				})
+				require.NoError(t, err)
+			}))
+			t.Cleanup(server.Close)
+
+			b, err := yaml.Marshal(&UpdateConfig{
+				Version: updateConfigVersion,
+				Kind:    updateConfigKind,
+				Spec: UpdateSpec{
+					Proxy: strings.TrimPrefix(server.URL, "https://"),
+				},
+				Status: UpdateStatus{
+					ActiveVersion: "old-version",
+				},
+			})
+			require.NoError(t, err)
+			err = os.WriteFile(cfgPath, b, 0600)
+			require.NoError(t, err)
+
+			updater, err := NewLocalUpdater(LocalUpdaterConfig{
+				InsecureSkipVerify: true,
+				VersionsDir:        dir,
+				ReportUpdates:      true,
+			})
+			require.NoError(t, err)
+
+			updater.Installer = &testInstaller{
+				FuncInstall: func(_ context.Context, version, template string, flags InstallFlags) error {
+					return tt.installErr
+				},
+				FuncLink: func(_ context.Context, version string) (revert func(context.Context) bool, err error) {
+					return func(_ context.Context) bool { return true }, nil
+				},
+				FuncList: func(_ context.Context) (versions []string, err error) {
+					return []string{"old"}, nil
+				},
+				FuncRemove: func(_ context.Context, version string) error {
+					return nil
+				},
+			}
+			updater.Process = &testProcess{
+				FuncSync:   func(_ context.Context) error { return nil },
+				FuncReload: func(_ context.Context) error { return nil },
+			}
+
+			err = updater.Enable(context.Background(), OverrideConfig{})
+			if tt.errMatch != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.errMatch)
+			} else {
+				require.NoError(t, err)
+			}
+			require.NotNil(t, report)
+			require.Equal(t, tt.reportedVersion, report.ActiveVersion)
+			require.Equal(t, tt.reportedSuccess, report.Success)
+			if tt.installErr != nil {
+				require.Contains(t, report.Error, tt.installErr.Error())
+			}
+		})
+	}
+}
+
+func TestUpdater_VersionCleanup(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name         string
+		keepVersions int
+		strict       bool
+		installed    []string
+		linked       []string
+
+		removed  []string
+		errMatch string
+	}{
+		{
+			name:      "extra versions removed",
+			installed: []string{"16.1.0", "16.2.0", "16.3.0", "backup-version"},
+
+			removed: []string{"16.1.0", "16.2.0"},
+		},
+		{
+			name:      "linked version never removed",
+			installed: []string{"16.1.0", "16.2.0", "16.3.0", "backup-version"},
+			linked:    []string{"16.1.0"},
+
+			removed: []string{"16.2.0", "backup-version"},
+		},
+		{
+			name:         "keep versions respected",
+			keepVersions: 4,
+			installed:    []string{"16.1.0", "16.2.0", "16.3.0", "backup-version"},
+
+			removed: nil,
+		},
+		{
+			name:      "strict limit satisfied",
+			strict:    true,
+			installed: []string{"16.1.0", "16.2.0", "16.3.0", "backup-version"},
+
+			removed: []string{"16.1.0", "16.2.0"},
+		},
+		{
+			name:      "strict limit exceeded",
+			strict:    true,
+			installed: []string{"16.1.0", "16.2.0", "16.3.0", "backup-version"},
+			linked:    []string{"16.1.0", "16.2.0"},
+
+			removed:  []string{"backup-version"},
+			errMatch: "exceeding the configured limit",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			cfgPath := filepath.Join(dir, "update.yaml")
+			b, err := yaml.Marshal(&UpdateConfig{
+				Version: updateConfigVersion,
+				Kind:    updateConfigKind,
+				Status: UpdateStatus{
+					ActiveVersion: "old-version",
+				},
+			})
+			require.NoError(t, err)
+			err = os.WriteFile(cfgPath, b, 0600)
+			require.NoError(t, err)
+
+			updater, err := NewLocalUpdater(LocalUpdaterConfig{
+				InsecureSkipVerify: true,
+				VersionsDir:        dir,
+				KeepVersions:       tt.keepVersions,
+				StrictVersionLimit: tt.strict,
+			})
+			require.NoError(t, err)
+
+			var removed []string
+			updater.Installer = &testInstaller{
+				FuncInstall: func(_ context.Context, version, template string, flags InstallFlags) error {
+					return nil
+				},
+				FuncLink: func(_ context.Context, version string) (revert func(context.Context) bool, err error) {
+					return func(_ context.Context) bool { return true }, nil
+				},
+				FuncList: func(_ context.Context) (versions []string, err error) {
+					return tt.installed, nil
+				},
+				FuncRemove: func(_ context.Context, version string) error {
+					if slices.Contains(tt.linked, version) {
+						return ErrLinked
+					}
+					removed = append(removed, version)
+					return nil
+				},
+			}
+			updater.Process = &testProcess{
+				FuncSync:   func(_ context.Context) error { return nil },
+				FuncReload: func(_ context.Context) error { return nil },
+			}
+
+			err = updater.Enable(context.Background(), OverrideConfig{
+				Proxy:        "localhost",
+				ForceVersion: "16.3.0",
+			})
+			if tt.errMatch != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.errMatch)
+			} else {
+				require.NoError(t, err)
+			}
+			require.Equal(t, tt.removed, removed)
+		})
+	}
+}
+
+func TestUpdater_Enable_TemplateFailover(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		spec      UpdateSpec
+		failing   []string
+		attempted []string
+		errMatch  string
+	}{
+		{
+			name: "first mirror fails",
+			spec: UpdateSpec{
+				URLTemplates: []string{"https://mirror1.example.com", "https://mirror2.example.com"},
+			},
+			failing:   []string{"https://mirror1.example.com"},
+			attempted: []string{"https://mirror1.example.com", "https://mirror2.example.com"},
+		},
+		{
+			name: "primary template tried before mirrors",
+			spec: UpdateSpec{
+				URLTemplate:  "https://primary.example.com",
+				URLTemplates: []string{"https://mirror1.example.com"},
+			},
+			failing:   []string{"https://primary.example.com"},
+			attempted: []string{"https://primary.example.com", "https://mirror1.example.com"},
+		},
+		{
+			name: "all mirrors fail",
+			spec: UpdateSpec{
+				URLTemplates: []string{"https://mirror1.example.com", "https://mirror2.example.com"},
+			},
+			failing:   []string{"https://mirror1.example.com", "https://mirror2.example.com"},
+			attempted: []string{"https://mirror1.example.com", "https://mirror2.example.com"},
+			errMatch:  "failed to install",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			cfgPath := filepath.Join(dir, "update.yaml")
+			b, err := yaml.Marshal(&UpdateConfig{
+				Version: updateConfigVersion,
+				Kind:    updateConfigKind,
+				Spec:    tt.spec,
+			})
+			require.NoError(t, err)
+			err = os.WriteFile(cfgPath, b, 0600)
+			require.NoError(t, err)
+
+			updater, err := NewLocalUpdater(LocalUpdaterConfig{
+				InsecureSkipVerify: true,
+				VersionsDir:        dir,
+			})
+			require.NoError(t, err)
+
+			var attempted []string
+			updater.Installer = &testInstaller{
+				FuncInstall: func(_ context.Context, version, template string, flags InstallFlags) error {
+					attempted = append(attempted, template)
+					if slices.Contains(tt.failing, template) {
+						return errors.New("mirror unavailable")
+					}
+					return nil
+				},
+				FuncLink: func(_ context.Context, version string) (revert func(context.Context) bool, err error) {
+					return func(_ context.Context) bool { return true }, nil
+				},
+				FuncList: func(_ context.Context) (versions []string, err error) {
+					return []string{"old"}, nil
+				},
+				FuncRemove: func(_ context.Context, version string) error {
+					return nil
+				},
+			}
+			updater.Process = &testProcess{
+				FuncSync:   func(_ context.Context) error { return nil },
+				FuncReload: func(_ context.Context) error { return nil },
+			}
+
+			err = updater.Enable(context.Background(), OverrideConfig{
+				Proxy:        "localhost",
+				ForceVersion: "16.3.0",
+			})
+			if tt.errMatch != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.errMatch)
+			} else {
+				require.NoError(t, err)
+			}
+			require.Equal(t, tt.attempted, attempted)
+		})
+	}
+}
+
+func TestNewLocalUpdater_RestartMode(t *testing.T) {
+	t.Parallel()
+
+	updater, err := NewLocalUpdater(LocalUpdaterConfig{
+		VersionsDir:   t.TempDir(),
+		RestartMode:   RestartModeHard,
+		ExtraServices: []string{"teleport-proxy.service"},
+	})
+	require.NoError(t, err)
+
+	process, ok := updater.Process.(*SystemdService)
+	require.True(t, ok)
+	require.Equal(t, RestartModeHard, process.Mode)
+	require.Len(t, updater.ExtraProcesses, 1)
+	extra, ok := updater.ExtraProcesses[0].(*SystemdService)
+	require.True(t, ok)
+	require.Equal(t, RestartModeHard, extra.Mode)
+}
+
+func TestNewLocalUpdater_ProxyCAPins(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(server.Close)
+	pin := libutils.CalculateSPKI(server.Certificate())
+
+	tests := []struct {
+		name     string
+		cfg      LocalUpdaterConfig
+		dialErr  bool
+		errMatch string
+	}{
+		{
+			name: "matching pin",
+			cfg: LocalUpdaterConfig{
+				ProxyCAPins: []string{pin},
+			},
+		},
+		{
+			name: "mismatched pin",
+			cfg: LocalUpdaterConfig{
+				ProxyCAPins: []string{"sha256:" + strings.Repeat("0", 64)},
+			},
+			dialErr: true,
+		},
+		{
+			name: "insecure with pins",
+			cfg: LocalUpdaterConfig{
+				InsecureSkipVerify: true,
+				ProxyCAPins:        []string{pin},
+			},
+			errMatch: "mutually exclusive",
+		},
+		{
+			name: "invalid pin algorithm",
+			cfg: LocalUpdaterConfig{
+				ProxyCAPins: []string{"sha512:abcdef"},
+			},
+			errMatch: "invalid certificate pin",
+		},
+		{
+			name: "invalid pin encoding",
+			cfg: LocalUpdaterConfig{
+				ProxyCAPins: []string{"sha256:not-hex"},
+			},
+			errMatch: "hex-encoded",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			tt.cfg.VersionsDir = t.TempDir()
+			updater, err := NewLocalUpdater(tt.cfg)
+			if tt.errMatch != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.errMatch)
+				return
+			}
+			require.NoError(t, err)
+
+			installer, ok := updater.Installer.(*LocalInstaller)
+			require.True(t, ok)
+			resp, err := installer.HTTP.Get(server.URL)
+			if tt.dialErr {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), "certificate pin")
+				return
+			}
+			require.NoError(t, err)
+			require.NoError(t, resp.Body.Close())
+		})
+	}
+}
+
+var serverRegexp = regexp.MustCompile("127.0.0.1:[0-9]+")
+
+func blankTestAddr(s []byte) []byte {
+	return serverRegexp.ReplaceAll(s, []byte("localhost"))
+}
+
+type testInstaller struct {
+	FuncInstall       func(ctx context.Context, version, template string, flags InstallFlags) error
+	FuncRemove        func(ctx context.Context, version string) error
+	FuncLink          func(ctx context.Context, version string) (revert func(context.Context) bool, err error)
+	FuncLinkedVersion func(ctx context.Context) (version string, err error)
+	FuncUnlink        func(ctx context.Context, version string) error
+	FuncList          func(ctx context.Context) (versions []string, err error)
+	FuncVerify        func(ctx context.Context, version string) error
+	FuncCheckDisk     func(ctx context.Context) error
+	FuncStat          func(ctx context.Context, version string) (InstallInfo, error)
+	FuncCleanup       func(ctx context.Context) error
+}
+
+func (ti *testInstaller) Install(ctx context.Context, version, template string, flags InstallFlags) error {
+	return ti.FuncInstall(ctx, version, template, flags)
+}
+
+func (ti *testInstaller) Remove(ctx context.Context, version string) error {
+	return ti.FuncRemove(ctx, version)
+}
+
+func (ti *testInstaller) Link(ctx context.Context, version string) (revert func(context.Context) bool, err error) {
+	return ti.FuncLink(ctx, version)
+}
+
+// LinkedVersion is checked on every Enable/Update, so tests that do not care
+// about link drift may leave FuncLinkedVersion unset.
+func (ti *testInstaller) LinkedVersion(ctx context.Context) (version string, err error) {
+	if ti.FuncLinkedVersion == nil {
+		return "", nil
+	}
+	return ti.FuncLinkedVersion(ctx)
+}
+
+func (ti *testInstaller) Unlink(ctx context.Context, version string) error {
+	return ti.FuncUnlink(ctx, version)
+}
+
+func (ti *testInstaller) List(ctx context.Context) (versions []string, err error) {
+	return ti.FuncList(ctx)
+}
+
+func (ti *testInstaller) Verify(ctx context.Context, version string) error {
+	return ti.FuncVerify(ctx, version)
+}
+
+func (ti *testInstaller) CheckDisk(ctx context.Context) error {
+	return ti.FuncCheckDisk(ctx)
+}
+
+func (ti *testInstaller) Stat(ctx context.Context, version string) (InstallInfo, error) {
+	return ti.FuncStat(ctx, version)
+}
+
+// Cleanup is best-effort and called on every Enable/Update, so tests that do
+// not care about it may leave FuncCleanup unset.
+func (ti *testInstaller) Cleanup(ctx context.Context) error {
+	if ti.FuncCleanup == nil {
+		return nil
+	}
+	return ti.FuncCleanup(ctx)
+}
+
+type testProcess struct {
+	FuncReload func(ctx context.Context) error
+	FuncSync   func(ctx context.Context) error
+	FuncStop   func(ctx context.Context) error
+}
+
+func (tp *testProcess) Reload(ctx context.Context) error {
+	return tp.FuncReload(ctx)
+}
+
+func (tp *testProcess) Sync(ctx context.Context) error {
+	return tp.FuncSync(ctx)
+}
+
+// Stop is only called on Uninstall, so tests that do not exercise it may
+// leave FuncStop unset.
+func (tp *testProcess) Stop(ctx context.Context) error {
+	if tp.FuncStop == nil {
+		return nil
+	}
+	return tp.FuncStop(ctx)
 }
@@ -20,6 +20,9 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"log/slog"
 	"os"
 	"os/signal"
@@ -83,6 +86,12 @@ type cliConfig struct {
 	LogFormat string
 	// DataDir for Teleport (usually /var/lib/teleport)
 	DataDir string
+	// NoPersistProxy uses the proxy specified with --proxy for this run only,
+	// without persisting it to the update configuration.
+	NoPersistProxy bool
+	// TeleportConfigPath is the path to the Teleport configuration file used
+	// as a fallback source for the proxy address.
+	TeleportConfigPath string
 	// LinkDir for linking binaries and systemd services
 	LinkDir string
 }
@@ -115,12 +124,28 @@ func Run(args []string) error {
 		Short('t').Envar(templateEnvVar).StringVar(&ccfg.URLTemplate)
 	enableCmd.Flag("force-version", "Force the provided version instead of querying it from the Teleport cluster.").
 		Short('f').Envar(updateVersionEnvVar).Hidden().StringVar(&ccfg.ForceVersion)
+	enableCmd.Flag("enterprise", "Install the Enterprise edition of Teleport. Required with --force-version for Enterprise clusters.").
+		BoolVar(&ccfg.Enterprise)
+	enableCmd.Flag("fips", "Install the FIPS flavor of Teleport. Required with --force-version for FIPS clusters.").
+		BoolVar(&ccfg.FIPS)
+	enableCmd.Flag("allow-downgrade", "Allow the agent to downgrade to a version older than the active version.").
+		BoolVar(&ccfg.AllowDowngrade)
+	enableCmd.Flag("no-persist-proxy", "Use the proxy specified with --proxy for this run only, without persisting it.").
+		BoolVar(&ccfg.NoPersistProxy)
+	enableCmd.Flag("config", "Teleport configuration file used as a fallback source for the proxy address.").
+		Short('c').Default(libdefaults.ConfigFilePath).StringVar(&ccfg.TeleportConfigPath)
 
 	disableCmd := app.Command("disable", "Disable agent auto-updates.")
 
+	// Forced versions are not supported for scheduled updates, which follow
+	// the cluster's rollout; use "enable --force-version" to pin a version.
 	updateCmd := app.Command("update", "Update agent to the latest version, if a new version is available.")
-	updateCmd.Flag("force-version", "Use the provided version instead of querying it from the Teleport cluster.").
-		Short('f').Envar(updateVersionEnvVar).Hidden().StringVar(&ccfg.ForceVersion)
+
+	preflightCmd := app.Command("preflight", "Validate that this host is ready for agent auto-updates.")
+
+	uninstallCmd := app.Command("uninstall", "Uninstall the Teleport system links, stopping any running Teleport services.")
+
+	inventoryCmd := app.Command("inventory", "Print the installed version inventory as JSON.")
 
 	libutils.UpdateAppUsageTemplate(app, args)
 	command, err := app.Parse(args)
@@ -141,6 +166,12 @@ func Run(args []string) error {
 		err = cmdDisable(ctx, &ccfg)
 	case updateCmd.FullCommand():
 		err = cmdUpdate(ctx, &ccfg)
+	case preflightCmd.FullCommand():
+		err = cmdPreflight(ctx, &ccfg)
+	case uninstallCmd.FullCommand():
+		err = cmdUninstall(ctx, &ccfg)
+	case inventoryCmd.FullCommand():
+		err = cmdInventory(ctx, &ccfg)
 	case versionCmd.FullCommand():
 		modules.GetModules().PrintVersion()
 	default:
@@ -216,6 +247,27 @@ func cmdEnable(ctx context.Context, ccfg *cliConfig) error {
 		}
 	}()
 
+	updater, err := autoupdate.NewLocalUpdater(autoupdate.LocalUpdaterConfig{
+		VersionsDir:        versionsDir,
+		LinkDir:            ccfg.LinkDir,
+		TeleportConfigPath: ccfg.TeleportConfigPath,
+		Log:                plog,
+	})
+	if err != nil {
+		return trace.Errorf("failed to setup updater: %w", err)
+	}
+	if ccfg.NoPersistProxy {
+		ccfg.ProxyPersist = autoupdate.ProxyPersistNever
+	}
+	if err := updater.Enable(ctx, ccfg.OverrideConfig); err != nil && !errors.Is(err, autoupdate.ErrNotNeeded) {
+		return trace.Wrap(err)
+	}
+	return nil
+}
+
+// cmdPreflight runs preflight checks and prints a per-check report.
+func cmdPreflight(ctx context.Context, ccfg *cliConfig) error {
+	versionsDir := filepath.Join(ccfg.DataDir, versionsDirName)
 	updater, err := autoupdate.NewLocalUpdater(autoupdate.LocalUpdaterConfig{
 		VersionsDir: versionsDir,
 		LinkDir:     ccfg.LinkDir,
@@ -224,7 +276,77 @@ func cmdEnable(ctx context.Context, ccfg *cliConfig) error {
 	if err != nil {
 		return trace.Errorf("failed to setup updater: %w", err)
 	}
-	if err := updater.Enable(ctx, ccfg.OverrideConfig); err != nil {
+	report, err := updater.Preflight(ctx)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	for _, check := range report.Checks {
+		if check.Passed {
+			fmt.Printf("PASS %s\n", check.Name)
+			continue
+		}
+		fmt.Printf("FAIL %s: %s\n", check.Name, check.Error)
+		if check.Hint != "" {
+			fmt.Printf("     hint: %s\n", check.Hint)
+		}
+	}
+	if !report.Passed() {
+		return trace.Errorf("preflight checks failed")
+	}
+	return nil
+}
+
+// cmdInventory prints the installed version inventory as JSON.
+func cmdInventory(ctx context.Context, ccfg *cliConfig) error {
+	versionsDir := filepath.Join(ccfg.DataDir, versionsDirName)
+	updater, err := autoupdate.NewLocalUpdater(autoupdate.LocalUpdaterConfig{
+		VersionsDir: versionsDir,
+		LinkDir:     ccfg.LinkDir,
+		Log:         plog,
+	})
+	if err != nil {
+		return trace.Errorf("failed to setup updater: %w", err)
+	}
+	inv, err := updater.Inventory(ctx)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	out, err := json.MarshalIndent(inv, "", "  ")
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	fmt.Println(string(out))
+	return nil
+}
+
+// cmdUninstall stops any running Teleport services and removes the Teleport
+// system links, leaving installed versions and update.yaml on disk.
+func cmdUninstall(ctx context.Context, ccfg *cliConfig) error {
+	versionsDir := filepath.Join(ccfg.DataDir, versionsDirName)
+	if err := os.MkdirAll(versionsDir, 0755); err != nil {
+		return trace.Errorf("failed to create versions directory: %w", err)
+	}
+
+	// Ensure uninstall can't run concurrently with an update.
+	unlock, err := libutils.FSWriteLock(filepath.Join(versionsDir, lockFileName))
+	if err != nil {
+		return trace.Errorf("failed to grab concurrent execution lock: %w", err)
+	}
+	defer func() {
+		if err := unlock(); err != nil {
+			plog.DebugContext(ctx, "Failed to close lock file", "error", err)
+		}
+	}()
+
+	updater, err := autoupdate.NewLocalUpdater(autoupdate.LocalUpdaterConfig{
+		VersionsDir: versionsDir,
+		LinkDir:     ccfg.LinkDir,
+		Log:         plog,
+	})
+	if err != nil {
+		return trace.Errorf("failed to setup updater: %w", err)
+	}
+	if err := updater.Uninstall(ctx); err != nil && !errors.Is(err, autoupdate.ErrNotNeeded) {
 		return trace.Wrap(err)
 	}
 	return nil
@@ -232,5 +354,32 @@ func cmdEnable(ctx context.Context, ccfg *cliConfig) error {
 
 // cmdUpdate updates Teleport to the version specified by cluster reachable at the proxy address.
 func cmdUpdate(ctx context.Context, ccfg *cliConfig) error {
-	return trace.NotImplemented("TODO")
+	versionsDir := filepath.Join(ccfg.DataDir, versionsDirName)
+	if err := os.MkdirAll(versionsDir, 0755); err != nil {
+		return trace.Errorf("failed to create versions directory: %w", err)
+	}
+
+	// Ensure update can't run concurrently.
+	unlock, err := libutils.FSWriteLock(filepath.Join(versionsDir, lockFileName))
+	if err != nil {
+		return trace.Errorf("failed to grab concurrent execution lock: %w", err)
+	}
+	defer func() {
+		if err := unlock(); err != nil {
+			plog.DebugContext(ctx, "Failed to close lock file", "error", err)
+		}
+	}()
+
+	updater, err := autoupdate.NewLocalUpdater(autoupdate.LocalUpdaterConfig{
+		VersionsDir: versionsDir,
+		LinkDir:     ccfg.LinkDir,
+		Log:         plog,
+	})
+	if err != nil {
+		return trace.Errorf("failed to setup updater: %w", err)
+	}
+	if err := updater.Update(ctx); err != nil && !errors.Is(err, autoupdate.ErrNotNeeded) {
+		return trace.Wrap(err)
+	}
+	return nil
 }